@@ -0,0 +1,55 @@
+// createadmin 创建第一个管理员，或把已有用户设为管理员
+//
+//	go run ./cmd/createadmin -name admin -password xxx
+//
+// 密码也可以通过环境变量 ADMIN_PASSWORD 传入，用户已存在时忽略密码
+package main
+
+import (
+	"context"
+	"demo/domain"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+func main() {
+	name := flag.String("name", "", "user name")
+	password := flag.String("password", os.Getenv("ADMIN_PASSWORD"), "password, only used when the user is created")
+	flag.Parse()
+	if *name == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	app := InitializeApp()
+	ctx := context.Background()
+	user, err := app.users.GetUserByName(ctx, *name)
+	switch {
+	case err == nil:
+		if err := app.users.UpdateRole(ctx, user.ID, domain.AccountAdmin); err != nil {
+			fail(err)
+		}
+		fmt.Printf("user %s (%s) is now admin\n", user.Name, user.ID)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		req := domain.CreateUserReq{Name: *name, Password: *password}
+		if err := req.Validate(); err != nil {
+			fail(err)
+		}
+		user, err := app.users.CreateUser(ctx, domain.User{Name: req.Name, Password: req.Password, Role: domain.AccountAdmin})
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("admin %s (%s) created\n", user.Name, user.ID)
+	default:
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
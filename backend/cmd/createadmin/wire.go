@@ -0,0 +1,30 @@
+//go:build wireinject
+// +build wireinject
+
+package main
+
+import (
+	"demo/config"
+	"demo/pkg/log"
+	"demo/pkg/store"
+	"demo/repo"
+
+	"github.com/google/wire"
+)
+
+type App struct {
+	users *repo.UserRepo
+}
+
+func InitializeApp() *App {
+	wire.Build(
+		wire.Struct(new(App), "*"),
+		wire.NewSet(
+			store.NewMySQL,
+			config.NewConfig,
+			log.ProviderSet,
+			repo.NewUserRepo,
+		),
+	)
+	return &App{}
+}
@@ -0,0 +1,33 @@
+// Code generated by Wire. DO NOT EDIT.
+
+//go:generate go run -mod=mod github.com/google/wire/cmd/wire
+//go:build !wireinject
+// +build !wireinject
+
+package main
+
+import (
+	"demo/config"
+	"demo/pkg/log"
+	"demo/pkg/store"
+	"demo/repo"
+)
+
+// Injectors from wire.go:
+
+func InitializeApp() *App {
+	configConfig := config.NewConfig()
+	logger := log.NewLogger(configConfig)
+	mySQL := store.NewMySQL(configConfig)
+	userRepo := repo.NewUserRepo(logger, configConfig, mySQL)
+	app := &App{
+		users: userRepo,
+	}
+	return app
+}
+
+// wire.go:
+
+type App struct {
+	users *repo.UserRepo
+}
@@ -1,9 +1,13 @@
 package main
 
-import "log"
+import (
+	"demo/hander/midwire"
+	"log"
+)
 
 func main() {
 	app := InitializeApp()
+	midwire.Init(app.keys)
 	if err := app.Service.Echo.StartTLS(app.config.Port, "./cert.pem", "./key.pem"); err != nil {
 		log.Fatal(err)
 		return
@@ -7,6 +7,7 @@ import (
 	"demo/config"
 	V1 "demo/hander/v1"
 	"demo/pkg/log"
+	"demo/pkg/token"
 	"demo/serve"
 
 	"github.com/google/wire"
@@ -16,6 +17,7 @@ type App struct {
 	Service *serve.HttpServer
 	config  *config.Config
 	v1      *V1.Handers
+	keys    *token.KeySet
 }
 
 func InitializeApp() *App {
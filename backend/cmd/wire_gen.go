@@ -12,6 +12,7 @@ import (
 	"demo/hander/v1"
 	"demo/pkg/log"
 	"demo/pkg/store"
+	"demo/pkg/token"
 	"demo/repo"
 	"demo/serve"
 	"demo/usecase"
@@ -26,27 +27,61 @@ func InitializeApp() *App {
 	mySQL := store.NewMySQL(configConfig)
 	conversationMessageRepo := repo.NewConversationRepo(logger, configConfig, mySQL)
 	roleRepo := repo.NewRoleRepo(logger, configConfig, mySQL)
-	llmUsecase := usecase.NewLlmUsecase(logger, configConfig, conversationMessageRepo, roleRepo)
-	helloHander := V1.NewHelloHander(httpServer, llmUsecase)
-	baseHandler := hander.NewBaseHandler()
-	userRepo := repo.NewUserRepo(logger, configConfig, mySQL)
-	userUsecase := usecase.NewUserUsecase(logger, userRepo, configConfig)
+	knowledgeRepo := repo.NewKnowledgeRepo(logger, configConfig, mySQL)
 	minio := store.NewMinioStore(configConfig)
 	fileUsecase := usecase.NewFileUsecase(logger, configConfig, minio)
+	knowledgeUsecase := usecase.NewKnowledgeUsecase(logger, knowledgeRepo, roleRepo, fileUsecase)
+	llmUsecase := usecase.NewLlmUsecase(logger, configConfig, conversationMessageRepo, roleRepo, knowledgeUsecase)
+	conversationUsecase := usecase.NewConversationUsecase(logger, conversationMessageRepo, roleRepo, fileUsecase)
+	roleUsecase := usecase.NewRoleUsecase(logger, roleRepo, knowledgeUsecase, fileUsecase)
+	usageRepo := repo.NewUsageRepo(logger, configConfig, mySQL)
+	usageUsecase := usecase.NewUsageUsecase(logger, configConfig, usageRepo)
+	moderationRepo := repo.NewModerationRepo(logger, configConfig, mySQL)
+	moderationUsecase := usecase.NewModerationUsecase(logger, configConfig, moderationRepo)
+	helloHander := V1.NewHelloHander(httpServer, logger, llmUsecase, conversationUsecase, roleUsecase, usageUsecase, moderationUsecase)
+	baseHandler := hander.NewBaseHandler()
+	userRepo := repo.NewUserRepo(logger, configConfig, mySQL)
+	tokenRepo := repo.NewTokenRepo(logger, configConfig, mySQL)
+	keySet := token.NewKeySet(logger, configConfig)
+	userUsecase := usecase.NewUserUsecase(logger, userRepo, tokenRepo, keySet, fileUsecase, configConfig)
 	asrUsecase := usecase.NewAsrUsecase(logger, configConfig)
-	wsUseCase := usecase.NewWsUsecase(logger, configConfig, asrUsecase, llmUsecase, fileUsecase)
+	ttsCache := usecase.NewTtsCache(logger, configConfig, fileUsecase)
+	wsUseCase := usecase.NewWsUsecase(logger, configConfig, asrUsecase, llmUsecase, fileUsecase, roleUsecase, moderationUsecase, ttsCache, conversationUsecase, userRepo, usageUsecase)
 	userHander := V1.NewUserHander(httpServer, baseHandler, logger, userUsecase, fileUsecase, wsUseCase)
-	roleUsecase := usecase.NewRoleUsecase(roleRepo)
 	roleHander := V1.NewRoleHander(httpServer, logger, baseHandler, roleUsecase)
+	knowledgeHander := V1.NewKnowledgeHander(httpServer, logger, baseHandler, knowledgeUsecase)
+	moderationHander := V1.NewModerationHander(httpServer, logger, baseHandler, moderationUsecase)
+	roleAdminHander := V1.NewRoleAdminHander(httpServer, logger, baseHandler, roleUsecase)
+	collectionRepo := repo.NewCollectionRepo(logger, configConfig, mySQL)
+	collectionUsecase := usecase.NewCollectionUsecase(collectionRepo, roleRepo)
+	collectionHander := V1.NewCollectionHander(httpServer, logger, baseHandler, collectionUsecase)
+	voiceUsecase := usecase.NewVoiceUsecase(logger, configConfig, fileUsecase)
+	userRoleUsecase := usecase.NewUserRoleUsecase(logger, configConfig, roleRepo, roleUsecase, voiceUsecase, moderationUsecase, fileUsecase)
+	userRoleHander := V1.NewUserRoleHander(httpServer, logger, baseHandler, userRoleUsecase)
+	voiceHander := V1.NewVoiceHander(httpServer, logger, baseHandler, voiceUsecase)
+	ttsUsecase := usecase.NewTtsUsecase(logger, configConfig, voiceUsecase, fileUsecase, usageUsecase, moderationUsecase)
+	ttsHander := V1.NewTtsHander(httpServer, logger, baseHandler, ttsUsecase, ttsCache)
+	conversationHander := V1.NewConversationHander(httpServer, logger, baseHandler, conversationUsecase)
+	usageHander := V1.NewUsageHander(httpServer, logger, baseHandler, usageUsecase)
 	handers := &V1.Handers{
-		Hello: helloHander,
-		User:  userHander,
-		Role:  roleHander,
+		Hello:        helloHander,
+		User:         userHander,
+		Role:         roleHander,
+		Knowledge:    knowledgeHander,
+		Moderation:   moderationHander,
+		RoleAdmin:    roleAdminHander,
+		Collection:   collectionHander,
+		UserRole:     userRoleHander,
+		Voice:        voiceHander,
+		Tts:          ttsHander,
+		Conversation: conversationHander,
+		Usage:        usageHander,
 	}
 	app := &App{
 		Service: httpServer,
 		config:  configConfig,
 		v1:      handers,
+		keys:    keySet,
 	}
 	return app
 }
@@ -57,4 +92,5 @@ type App struct {
 	Service *serve.HttpServer
 	config  *config.Config
 	v1      *V1.Handers
+	keys    *token.KeySet
 }
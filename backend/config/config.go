@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -13,14 +14,20 @@ type TtsConfig struct {
 }
 
 type Config struct {
-	EndPoint  string
-	Port      string
-	ServeName string
-	MySQL     MySQLConfig
-	Log       LogConfig
-	Asr       AsrConfig
-	Tts       TtsConfig
-	Oss       OssConfig
+	EndPoint   string
+	Port       string
+	ServeName  string
+	MySQL      MySQLConfig
+	Log        LogConfig
+	Asr        AsrConfig
+	Tts        TtsConfig
+	Oss        OssConfig
+	Llm        LlmConfig
+	Moderation ModerationConfig
+	UserRole   UserRoleConfig
+	Jwt        JwtConfig
+	Guest      GuestConfig
+	Quota      QuotaConfig
 }
 type OssConfig struct {
 	EndPoint   string
@@ -33,6 +40,56 @@ type AsrConfig struct {
 	ModelId string
 	ApiKey  string
 }
+
+// LlmConfig 按顺序排列的模型列表，前一个超时或出错时回退到下一个
+type LlmConfig struct {
+	Models []LlmModelConfig
+	// FirstTokenTimeout 等待首个 token 的毫秒数
+	FirstTokenTimeout int
+}
+type LlmModelConfig struct {
+	Model   string
+	BaseUrl string
+	ApiKey  string
+}
+
+// ModerationConfig 内容审核：RulesFile 为本地规则 JSON 文件，RemoteUrl 非空时额外调用远程审核服务
+type ModerationConfig struct {
+	RulesFile    string
+	RemoteUrl    string
+	RemoteApiKey string
+}
+
+// UserRoleConfig 用户自建角色，Quota 为每个用户最多创建的角色数
+type UserRoleConfig struct {
+	Quota int
+}
+
+// JwtConfig 签名密钥按 kid 区分，新 token 用 CurrentKid 签发，其余密钥只用于验证轮换前签发的 token
+type JwtConfig struct {
+	Keys       map[string]string
+	CurrentKid string
+	// AccessTTL、RefreshTTL 单位为秒
+	AccessTTL  int
+	RefreshTTL int
+}
+
+// GuestConfig 免注册试用：TurnLimit 为游客最多对话的轮数，TTL 为游客身份的有效秒数
+type GuestConfig struct {
+	TurnLimit int
+	TTL       int
+}
+
+// QuotaConfig 每个用户每天、每月可用的第三方接口用量，0 表示不限
+type QuotaConfig struct {
+	DailyTokens       int64
+	DailyAsrSeconds   int64
+	DailyTtsChars     int64
+	MonthlyTokens     int64
+	MonthlyAsrSeconds int64
+	MonthlyTtsChars   int64
+}
+
 type LogConfig struct {
 	Level int
 }
@@ -72,5 +129,98 @@ func NewConfig() *Config {
 	c.Oss.AccessKey = os.Getenv("MINIO_ACCESS_KEY")
 	c.Oss.SecretKey = os.Getenv("MINIO_SECRET_KEY")
 	c.Oss.BucketName = os.Getenv("OSS_BUCKET")
+	// LLM_MODELS=deepseek-v3,qwen-turbo@https://other/v1 ，不写地址时使用默认地址
+	if models := os.Getenv("LLM_MODELS"); models != "" {
+		c.Llm.Models = nil
+		for _, m := range strings.Split(models, ",") {
+			m = strings.TrimSpace(m)
+			if m == "" {
+				continue
+			}
+			name, baseUrl, _ := strings.Cut(m, "@")
+			c.Llm.Models = append(c.Llm.Models, LlmModelConfig{Model: name, BaseUrl: baseUrl})
+		}
+	}
+	if len(c.Llm.Models) == 0 {
+		c.Llm.Models = []LlmModelConfig{{Model: "deepseek-v3"}}
+	}
+	for i := range c.Llm.Models {
+		if c.Llm.Models[i].BaseUrl == "" {
+			c.Llm.Models[i].BaseUrl = c.Asr.BaseUrl
+		}
+		if c.Llm.Models[i].ApiKey == "" {
+			c.Llm.Models[i].ApiKey = c.Asr.ApiKey
+		}
+	}
+	c.Moderation.RulesFile = os.Getenv("MODERATION_RULES_FILE")
+	c.Moderation.RemoteUrl = os.Getenv("MODERATION_URL")
+	c.Moderation.RemoteApiKey = os.Getenv("MODERATION_API_KEY")
+	if v, err := strconv.Atoi(os.Getenv("USER_ROLE_QUOTA")); err == nil {
+		c.UserRole.Quota = v
+	}
+	if c.UserRole.Quota <= 0 {
+		c.UserRole.Quota = 10
+	}
+	// JWT_KEYS=kid1:secret1,kid2:secret2 ，第一个为当前签发用的密钥，也可以用 JWT_KID 指定
+	if keys := os.Getenv("JWT_KEYS"); keys != "" {
+		c.Jwt.Keys = map[string]string{}
+		c.Jwt.CurrentKid = ""
+		for _, kv := range strings.Split(keys, ",") {
+			kid, secret, ok := strings.Cut(strings.TrimSpace(kv), ":")
+			if !ok || kid == "" || secret == "" {
+				continue
+			}
+			c.Jwt.Keys[kid] = secret
+			if c.Jwt.CurrentKid == "" {
+				c.Jwt.CurrentKid = kid
+			}
+		}
+	}
+	if kid := os.Getenv("JWT_KID"); kid != "" {
+		c.Jwt.CurrentKid = kid
+	}
+	if v, err := strconv.Atoi(os.Getenv("JWT_ACCESS_TTL")); err == nil {
+		c.Jwt.AccessTTL = v
+	}
+	if c.Jwt.AccessTTL <= 0 {
+		c.Jwt.AccessTTL = 15 * 60
+	}
+	if v, err := strconv.Atoi(os.Getenv("JWT_REFRESH_TTL")); err == nil {
+		c.Jwt.RefreshTTL = v
+	}
+	if c.Jwt.RefreshTTL <= 0 {
+		c.Jwt.RefreshTTL = 30 * 24 * 3600
+	}
+	if v, err := strconv.Atoi(os.Getenv("GUEST_TURN_LIMIT")); err == nil {
+		c.Guest.TurnLimit = v
+	}
+	if c.Guest.TurnLimit <= 0 {
+		c.Guest.TurnLimit = 20
+	}
+	if v, err := strconv.Atoi(os.Getenv("GUEST_TTL")); err == nil {
+		c.Guest.TTL = v
+	}
+	if c.Guest.TTL <= 0 {
+		c.Guest.TTL = 7 * 24 * 3600
+	}
+	// QUOTA_DAILY_TOKENS、QUOTA_MONTHLY_ASR_SECONDS 等，不设置时不限
+	for env, v := range map[string]*int64{
+		"QUOTA_DAILY_TOKENS":        &c.Quota.DailyTokens,
+		"QUOTA_DAILY_ASR_SECONDS":   &c.Quota.DailyAsrSeconds,
+		"QUOTA_DAILY_TTS_CHARS":     &c.Quota.DailyTtsChars,
+		"QUOTA_MONTHLY_TOKENS":      &c.Quota.MonthlyTokens,
+		"QUOTA_MONTHLY_ASR_SECONDS": &c.Quota.MonthlyAsrSeconds,
+		"QUOTA_MONTHLY_TTS_CHARS":   &c.Quota.MonthlyTtsChars,
+	} {
+		if n, err := strconv.ParseInt(os.Getenv(env), 10, 64); err == nil && n > 0 {
+			*v = n
+		}
+	}
+	if v, err := strconv.Atoi(os.Getenv("LLM_FIRST_TOKEN_TIMEOUT_MS")); err == nil {
+		c.Llm.FirstTokenTimeout = v
+	}
+	if c.Llm.FirstTokenTimeout <= 0 {
+		c.Llm.FirstTokenTimeout = 5000
+	}
 	return c
 }
@@ -1,5 +1,7 @@
 package domain
 
+import "strconv"
+
 type AsrResponse struct {
 	Data struct {
 		Result struct {
@@ -22,3 +24,12 @@ type AsrResponse struct {
   }
 }
 */
+
+// DurationMs 接口计费的音频时长，取自 additions.duration，没有时返回 0
+func (r *AsrResponse) DurationMs() int64 {
+	if r == nil {
+		return 0
+	}
+	ms, _ := strconv.ParseInt(r.Data.Result.Additions["duration"], 10, 64)
+	return ms
+}
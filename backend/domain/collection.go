@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// UserRoleLike 用户点赞记录，联合主键保证同一用户只能点赞一次
+type UserRoleLike struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey;type:varchar(64)"`
+	RoleID    int       `json:"role_id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserRoleFavorite 用户收藏的角色
+type UserRoleFavorite struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey;type:varchar(64)"`
+	RoleID    int       `json:"role_id" gorm:"primaryKey;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LikeResp struct {
+	Liked bool `json:"liked"`
+	Likes int  `json:"likes"`
+}
+
+type FavoriteResp struct {
+	Favorited bool `json:"favorited"`
+}
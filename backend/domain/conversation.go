@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// Conversation 用户与角色的一个对话线程，同一角色可以有多个
+type Conversation struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"type:varchar(64);index:idx_conversation_user_role"`
+	RoleID    int       `json:"role_id" gorm:"index:idx_conversation_user_role"`
+	Title     string    `json:"title" gorm:"type:varchar(128)"`
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// conversationTitleLen 自动标题取第一个问题的前几个字
+const conversationTitleLen = 20
+
+// DefaultConversationTitle 没有设置标题时用第一个问题作为标题
+func DefaultConversationTitle(question string) string {
+	if utf8.RuneCountInString(question) <= conversationTitleLen {
+		return question
+	}
+	return string([]rune(question)[:conversationTitleLen]) + "…"
+}
+
+type CreateConversationReq struct {
+	RoleID int    `json:"role_id"`
+	Title  string `json:"title"`
+}
+
+// UpdateConversationReq 只修改传了的字段
+type UpdateConversationReq struct {
+	Title    *string `json:"title"`
+	Archived *bool   `json:"archived"`
+}
+
+// ConversationQuery 列表过滤条件，RoleID 为 0 时不过滤
+type ConversationQuery struct {
+	RoleID   int  `query:"role_id"`
+	Archived bool `query:"archived"` //true 时只列出归档的对话
+}
+
+// ConversationSummary 对话列表中的一项
+type ConversationSummary struct {
+	Conversation
+	RoleName     string `json:"role_name"`
+	RoleImageUrl string `json:"role_image_url"`
+	LastMessage  string `json:"last_message"`
+	LastSender   string `json:"last_sender"` //user 或 assistant
+	MessageCount int    `json:"message_count"`
+}
+
+type ConversationList struct {
+	Conversations []ConversationSummary `json:"conversations"`
+}
+
+// MessageList 按时间倒序分页，取上一页最小的 id 作为 before_id 继续翻页
+type MessageList struct {
+	Messages     []ConversationMessage `json:"messages"`
+	NextBeforeID int                   `json:"next_before_id,omitempty"`
+}
+
+type ClearConversationResp struct {
+	Deleted int64 `json:"deleted"`
+}
+
+// 对话导出格式
+const (
+	ExportJSON     = "json"
+	ExportMarkdown = "md"
+	ExportWav      = "wav" //按顺序拼接双方的录音
+	ExportMp3      = "mp3" //同 wav，编码成 mp3
+)
+
+// ConversationExport 导出的完整对话，只包含用户可见的消息
+type ConversationExport struct {
+	ConversationID int                   `json:"conversation_id"`
+	Title          string                `json:"title"`
+	RoleID         int                   `json:"role_id"`
+	RoleName       string                `json:"role_name"`
+	UserID         string                `json:"user_id"`
+	ExportedAt     time.Time             `json:"exported_at"`
+	Messages       []ConversationMessage `json:"messages"`
+}
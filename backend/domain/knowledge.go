@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+// KnowledgeDocument 角色知识库中的一份资料（原文件存放在 OSS）
+type KnowledgeDocument struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	RoleID    int       `json:"role_id" gorm:"index"`
+	Name      string    `json:"name"`
+	FileKey   string    `json:"file_key"`
+	Chunks    int       `json:"chunks"` //切分出的段落数
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KnowledgeChunk 资料切分后的段落，检索的最小单位
+type KnowledgeChunk struct {
+	ID         int    `json:"id" gorm:"primaryKey"`
+	DocumentID int    `json:"document_id" gorm:"index"`
+	RoleID     int    `json:"role_id" gorm:"index"`
+	Seq        int    `json:"seq"`
+	Content    string `json:"content" gorm:"type:text"`
+}
+
+// KnowledgePassage 检索命中的段落
+type KnowledgePassage struct {
+	DocumentID int     `json:"document_id"`
+	Content    string  `json:"content"`
+	Score      float64 `json:"score"`
+}
+
+type KnowledgeDocumentList struct {
+	Documents []KnowledgeDocument `json:"documents"`
+}
+
+type KnowledgeSearchResp struct {
+	Passages []KnowledgePassage `json:"passages"`
+}
+
+const KnowledgePrompt = `以下是与用户问题相关的角色资料，回答时可以参考，但要用角色自己的口吻自然地说出来，不要提到“资料”：
+`
@@ -11,8 +11,24 @@ type ConversationMessage struct {
 	ID     int    `json:"id" gorm:"primaryKey"`
 	RoleID int    `json:"role_id"` //AI扮演的角色
 	UserID string `json:"user_id"`
+	//所属的对话线程
+	ConversationID int `json:"conversation_id" gorm:"index"`
 
 	Role    schema.RoleType `json:"role"` //用户  AI
 	Content string          `json:"content"`
-	Time    time.Time       `json:"time"`
+	Model   string          `json:"model,omitempty"` //实际应答的模型
+	//工具调用：assistant 消息上记录发起的调用，tool 消息记录调用结果
+	ToolCalls  []schema.ToolCall `json:"tool_calls,omitempty" gorm:"serializer:json"`
+	ToolCallID string            `json:"tool_call_id,omitempty"`
+	ToolName   string            `json:"tool_name,omitempty"`
+	//用户说的原始录音或角色回答的合成语音
+	MessageAudio
+	Time time.Time `json:"time"`
+}
+
+// MessageAudio 消息对应的音频（OSS 上的 WAV 文件），没有音频时为空
+type MessageAudio struct {
+	AudioKey      string `json:"audio_key,omitempty"`
+	AudioDuration int    `json:"audio_duration_ms,omitempty"` //毫秒
+	AudioUrl      string `json:"audio_url,omitempty" gorm:"-"`
 }
@@ -0,0 +1,64 @@
+package domain
+
+import "time"
+
+// ModerationAction 审核结论，按严重程度递增
+type ModerationAction string
+
+const (
+	ModerationPass    ModerationAction = "pass"
+	ModerationFlag    ModerationAction = "flag"    //放行但记录
+	ModerationRewrite ModerationAction = "rewrite" //替换命中内容后放行
+	ModerationBlock   ModerationAction = "block"   //拦截，角色回复拒绝语
+)
+
+// ModerationDirection 审核方向：用户输入（ASR 文本）或模型输出（TTS 前）
+type ModerationDirection string
+
+const (
+	ModerationInput  ModerationDirection = "input"
+	ModerationOutput ModerationDirection = "output"
+)
+
+// ModerationRule 本地关键词/正则规则，Direction 为空时两个方向都生效
+type ModerationRule struct {
+	Pattern     string              `json:"pattern"`
+	Regex       bool                `json:"regex"`
+	Action      ModerationAction    `json:"action"`
+	Replacement string              `json:"replacement"` //rewrite 时的替换文本，默认 *
+	Direction   ModerationDirection `json:"direction"`
+	Reason      string              `json:"reason"`
+}
+
+type ModerationResult struct {
+	Action ModerationAction `json:"action"`
+	Text   string           `json:"text"` //rewrite 后的文本
+	Reason string           `json:"reason"`
+	Source string           `json:"source"` //给出结论的审核器
+}
+
+// ModerationEvent 非 pass 的审核记录
+type ModerationEvent struct {
+	ID        int                 `json:"id" gorm:"primaryKey"`
+	UserID    string              `json:"user_id" gorm:"index"`
+	RoleID    int                 `json:"role_id"`
+	Direction ModerationDirection `json:"direction"`
+	Action    ModerationAction    `json:"action"`
+	Source    string              `json:"source"`
+	Reason    string              `json:"reason"`
+	Content   string              `json:"content" gorm:"type:text"`
+	CreatedAt time.Time           `json:"created_at" gorm:"index"`
+}
+
+type ModerationEventList struct {
+	Events []ModerationEvent `json:"events"`
+}
+
+// DefaultModerationRules 内置规则：输出中的手机号、身份证号打码
+var DefaultModerationRules = []ModerationRule{
+	{Pattern: `1[3-9]\d{9}`, Regex: true, Action: ModerationRewrite, Replacement: "某个号码", Direction: ModerationOutput, Reason: "phone number"},
+	{Pattern: `\d{17}[\dXx]`, Regex: true, Action: ModerationRewrite, Replacement: "某个号码", Direction: ModerationOutput, Reason: "id card number"},
+}
+
+// DefaultRefusal 角色没有配置拒绝语时使用
+const DefaultRefusal = "这个话题我不方便聊，我们换个话题吧。"
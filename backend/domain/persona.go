@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Persona 结构化的角色设定，编译成 system 消息和 few-shot 示例对话
+type Persona struct {
+	Background      string           `json:"background"`       //身份、经历、性格
+	SpeakingStyle   string           `json:"speaking_style"`   //说话风格，eg：语速慢，喜欢反问
+	Catchphrases    []string         `json:"catchphrases"`     //口头禅
+	Era             string           `json:"era"`              //所处时代
+	KnowledgeCutoff string           `json:"knowledge_cutoff"` //知识截止，超出的事物应表示不知道
+	TabooTopics     []string         `json:"taboo_topics"`     //拒绝谈论的话题
+	Examples        []PersonaExample `json:"examples"`         //示例对话
+	Greeting        string           `json:"greeting"`         //开场白
+}
+
+type PersonaExample struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+}
+
+const (
+	personaMaxText     = 2000
+	personaMaxLine     = 200
+	personaMaxItems    = 20
+	personaMaxExamples = 10
+)
+
+// IsZero 角色没有配置结构化设定
+func (p Persona) IsZero() bool {
+	return p.Background == "" && p.SpeakingStyle == "" && len(p.Catchphrases) == 0 && p.Era == "" &&
+		p.KnowledgeCutoff == "" && len(p.TabooTopics) == 0 && len(p.Examples) == 0 && p.Greeting == ""
+}
+
+// Validate 检查各字段长度和示例对话是否完整
+func (p Persona) Validate() error {
+	var errs []error
+	checkLen := func(field, s string, max int) {
+		if utf8.RuneCountInString(s) > max {
+			errs = append(errs, fmt.Errorf("persona.%s exceeds %d characters", field, max))
+		}
+	}
+	checkList := func(field string, list []string) {
+		if len(list) > personaMaxItems {
+			errs = append(errs, fmt.Errorf("persona.%s has more than %d items", field, personaMaxItems))
+		}
+		for i, s := range list {
+			if strings.TrimSpace(s) == "" {
+				errs = append(errs, fmt.Errorf("persona.%s[%d] is empty", field, i))
+			}
+			checkLen(fmt.Sprintf("%s[%d]", field, i), s, personaMaxLine)
+		}
+	}
+	checkLen("background", p.Background, personaMaxText)
+	checkLen("speaking_style", p.SpeakingStyle, personaMaxLine*2)
+	checkLen("era", p.Era, personaMaxLine)
+	checkLen("knowledge_cutoff", p.KnowledgeCutoff, personaMaxLine)
+	checkLen("greeting", p.Greeting, personaMaxLine)
+	checkList("catchphrases", p.Catchphrases)
+	checkList("taboo_topics", p.TabooTopics)
+	if len(p.Examples) > personaMaxExamples {
+		errs = append(errs, fmt.Errorf("persona.examples has more than %d items", personaMaxExamples))
+	}
+	for i, e := range p.Examples {
+		if strings.TrimSpace(e.User) == "" || strings.TrimSpace(e.Assistant) == "" {
+			errs = append(errs, fmt.Errorf("persona.examples[%d] needs both user and assistant", i))
+		}
+		checkLen(fmt.Sprintf("examples[%d].user", i), e.User, personaMaxLine*2)
+		checkLen(fmt.Sprintf("examples[%d].assistant", i), e.Assistant, personaMaxLine*2)
+	}
+	return errors.Join(errs...)
+}
+
+// SystemPrompt 把设定编译成 system 提示词
+func (p Persona) SystemPrompt(name string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "你现在扮演「%s」，始终以该角色的身份和口吻说话，不要承认自己是 AI。\n", name)
+	if p.Background != "" {
+		fmt.Fprintf(&sb, "【背景】%s\n", p.Background)
+	}
+	if p.Era != "" {
+		fmt.Fprintf(&sb, "【时代】%s\n", p.Era)
+	}
+	if p.KnowledgeCutoff != "" {
+		fmt.Fprintf(&sb, "【知识范围】你只知道%s之前的事，对之后出现的事物要表现出不了解。\n", p.KnowledgeCutoff)
+	}
+	if p.SpeakingStyle != "" {
+		fmt.Fprintf(&sb, "【说话风格】%s\n", p.SpeakingStyle)
+	}
+	if len(p.Catchphrases) > 0 {
+		fmt.Fprintf(&sb, "【口头禅】%s（自然地偶尔使用，不要每句都用）\n", strings.Join(p.Catchphrases, "、"))
+	}
+	if len(p.TabooTopics) > 0 {
+		fmt.Fprintf(&sb, "【禁忌话题】%s。用户提到时以角色的方式婉拒并转移话题。\n", strings.Join(p.TabooTopics, "、"))
+	}
+	return sb.String()
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+)
+
+// 账号角色，和对话用的 Role 无关
+const (
+	AccountUser   = "user"
+	AccountEditor = "editor"
+	AccountAdmin  = "admin"
+	AccountGuest  = "guest" //免注册试用，注册后转为 user
+)
+
+// 权限，签发 access token 时写入 perms claim
+const (
+	PermRolesManage     = "roles:manage"     //管理官方角色
+	PermRolesReview     = "roles:review"     //审核用户公开的角色
+	PermKnowledgeManage = "knowledge:manage" //管理角色知识库
+	PermModerationView  = "moderation:view"  //查看审核记录
+	PermSystemView      = "system:view"      //查看缓存等运行状态
+	PermUsersManage     = "users:manage"     //修改其他用户的账号角色
+)
+
+var accountPermissions = map[string][]string{
+	AccountGuest:  {},
+	AccountUser:   {},
+	AccountEditor: {PermRolesManage, PermRolesReview, PermKnowledgeManage},
+	AccountAdmin: {
+		PermRolesManage, PermRolesReview, PermKnowledgeManage,
+		PermModerationView, PermSystemView, PermUsersManage,
+	},
+}
+
+// ValidAccountRole 是否为已知的账号角色
+func ValidAccountRole(role string) bool {
+	_, ok := accountPermissions[role]
+	return ok
+}
+
+// Permissions 返回账号角色拥有的权限，未知角色按普通用户处理
+func Permissions(role string) []string {
+	return slices.Clone(accountPermissions[role])
+}
+
+// UpdateAccountRoleReq 管理员修改用户的账号角色
+type UpdateAccountRoleReq struct {
+	Role string `json:"role"`
+}
+
+func (r UpdateAccountRoleReq) Validate() error {
+	if !ValidAccountRole(r.Role) || r.Role == AccountGuest {
+		return fmt.Errorf("invalid role %q, must be one of user, editor, admin", r.Role)
+	}
+	return nil
+}
@@ -1,30 +1,296 @@
 package domain
 
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
 type Role struct {
 	ID       int    `json:"id"`
 	Name     string `json:"name"`
 	Prompt   string `json:"prompt"`
 	ImageUrl string `json:"image_url"`
+	//简介、分类和标签，用于搜索
+	Description string   `json:"description" gorm:"type:text"`
+	Category    string   `json:"category" gorm:"type:varchar(64);index"`
+	Tags        []string `json:"tags" gorm:"type:text;serializer:json"`
 	//音色
 	Voice string `json:"voice"` //eg：qiniu_zh_female_tmjxxy
+	//音色试听地址
+	VoiceSampleUrl string `json:"voice_sample_url"`
 	//浏览量
 	Views int `json:"views"`
 	//点赞量
 	Likes int `json:"likes"`
+	//角色可调用的工具，eg：["current_time","roll_dice"]
+	Tools []string `json:"tools" gorm:"serializer:json"`
+	//朗读前的文本清理配置，默认全部清理
+	Speech SpeechOptions `json:"speech" gorm:"serializer:json"`
+	//内容被拦截时角色说的拒绝语，为空时使用 DefaultRefusal
+	Refusal string `json:"refusal"`
+	//结构化角色设定，配置后与 Prompt 一起编译进 system 消息
+	Persona Persona `json:"persona" gorm:"serializer:json"`
+	//连接语音会话时的开场方式，为空时不主动说话
+	GreetingMode string `json:"greeting_mode"`
+	//draft 只在管理接口可见，published 对用户可见
+	Status string `json:"status" gorm:"type:varchar(16);default:published;index"`
+	//创建者，官方角色为空
+	OwnerID string `json:"owner_id" gorm:"type:varchar(64);index"`
+	//private 只有创建者可用，public 审核通过后出现在列表中
+	Visibility string `json:"visibility" gorm:"type:varchar(16);default:public"`
+	//公开前的审核状态，官方角色默认通过
+	ReviewStatus string `json:"review_status" gorm:"type:varchar(16);default:approved;index"`
+	ReviewNote   string `json:"review_note"`
+	//当前提示词版本号，每次修改 Prompt 或 Persona 加一
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// VisibleTo 已发布且公开、审核通过的角色所有人可用，用户自建的角色创建者始终可用
+func (r Role) VisibleTo(userID string) bool {
+	if r.Status != RolePublished {
+		return false
+	}
+	if r.OwnerID != "" && r.OwnerID == userID {
+		return true
+	}
+	return r.Visibility != RolePrivate && (r.ReviewStatus == "" || r.ReviewStatus == ReviewApproved)
+}
+
+// Validate 创建、更新角色前的校验
+func (r Role) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("role name is required")
+	}
+	if strings.TrimSpace(r.Prompt) == "" && r.Persona.IsZero() {
+		return errors.New("role needs a prompt or a persona")
+	}
+	switch r.GreetingMode {
+	case GreetingNone, GreetingWelcomeBack:
+	case GreetingFixed:
+		if strings.TrimSpace(r.Persona.Greeting) == "" {
+			return errors.New("greeting_mode fixed needs persona.greeting")
+		}
+	default:
+		return fmt.Errorf("unknown greeting_mode: %s", r.GreetingMode)
+	}
+	for _, name := range r.Tools {
+		if !slices.Contains(RoleTools, name) {
+			return fmt.Errorf("%w: %s", ErrUnknownTool, name)
+		}
+	}
+	return r.Persona.Validate()
+}
+
+// SpeechOptions 控制回复送去 TTS 前保留哪些内容，零值表示全部清理
+type SpeechOptions struct {
+	KeepActions  bool `json:"keep_actions"`  //保留括号、星号中的动作和旁白
+	KeepMarkdown bool `json:"keep_markdown"` //保留 markdown 标记和引号
+	KeepEmoji    bool `json:"keep_emoji"`
+	KeepUrls     bool `json:"keep_urls"`
+	RawNumbers   bool `json:"raw_numbers"` //不把数字、日期转成中文读法
 }
 type RoleWithoutPrompt struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	ImageUrl string `json:"image_url"`
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	ImageUrl    string   `json:"image_url"`
+	Description string   `json:"description"`
+	Category    string   `json:"category"`
+	Tags        []string `json:"tags"`
 	//音色
 	Voice string `json:"voice"` //eg：qiniu_zh_female_tmjxxy
 	//浏览量
+	Views int `json:"views"`
+	//点赞量
 	Likes int `json:"likes"`
 }
+
+// RoleDetail 角色详情页
+type RoleDetail struct {
+	RoleWithoutPrompt
+	VoiceSampleUrl string `json:"voice_sample_url"`
+	Greeting       string `json:"greeting"`
+}
+
+// Public 对用户公开的字段
+func (r Role) Public() RoleWithoutPrompt {
+	return RoleWithoutPrompt{
+		ID:          r.ID,
+		Name:        r.Name,
+		ImageUrl:    r.ImageUrl,
+		Description: r.Description,
+		Category:    r.Category,
+		Tags:        r.Tags,
+		Voice:       r.Voice,
+		Views:       r.Views,
+		Likes:       r.Likes,
+	}
+}
+
 type RoleList struct {
 	Roles []RoleWithoutPrompt `json:"roles"`
+	//下一页的游标，为空表示没有更多
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// 角色列表排序方式
+const (
+	RoleSortRelevance = "relevance" //有搜索词时的默认排序
+	RoleSortNewest    = "newest"
+	RoleSortViews     = "views"
+	RoleSortLikes     = "likes"
+)
+
+// RoleQuery 角色搜索条件
+type RoleQuery struct {
+	Q        string `query:"q"`
+	Category string `query:"category"`
+	Tag      string `query:"tag"`
+	Sort     string `query:"sort"`
+	Cursor   string `query:"cursor"`
+	Limit    int    `query:"limit"`
+}
+
+// 角色状态
+const (
+	RoleDraft     = "draft"
+	RolePublished = "published"
+)
+
+// 可见性
+const (
+	RolePublic  = "public"
+	RolePrivate = "private"
+)
+
+// 审核状态
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// UserRoleReq 用户创建、修改自己的角色
+type UserRoleReq struct {
+	Name         string   `json:"name"`
+	ImageUrl     string   `json:"image_url"` //先通过头像上传接口拿到
+	Description  string   `json:"description"`
+	Category     string   `json:"category"`
+	Tags         []string `json:"tags"`
+	Voice        string   `json:"voice"` //音色列表中的 voice_type
+	Persona      Persona  `json:"persona"`
+	GreetingMode string   `json:"greeting_mode"`
+	Visibility   string   `json:"visibility"`
+}
+
+// Apply 把请求内容写到角色上
+func (r UserRoleReq) Apply(role *Role) {
+	role.Name = r.Name
+	role.ImageUrl = r.ImageUrl
+	role.Description = r.Description
+	role.Category = r.Category
+	role.Tags = r.Tags
+	role.Voice = r.Voice
+	role.Persona = r.Persona
+	role.GreetingMode = r.GreetingMode
+	role.Visibility = r.Visibility
+}
+
+// ReviewRoleReq 管理员审核用户公开的角色
+type ReviewRoleReq struct {
+	Approved bool   `json:"approved"`
+	Note     string `json:"note"`
+}
+
+type AvatarResp struct {
+	ImageUrl string `json:"image_url"`
+}
+
+// SaveRoleReq 管理接口创建、修改角色的请求
+type SaveRoleReq struct {
+	Name           string        `json:"name"`
+	Prompt         string        `json:"prompt"`
+	ImageUrl       string        `json:"image_url"`
+	Description    string        `json:"description"`
+	Category       string        `json:"category"`
+	Tags           []string      `json:"tags"`
+	Voice          string        `json:"voice"`
+	VoiceSampleUrl string        `json:"voice_sample_url"`
+	Tools          []string      `json:"tools"`
+	Speech         SpeechOptions `json:"speech"`
+	Refusal        string        `json:"refusal"`
+	Persona        Persona       `json:"persona"`
+	GreetingMode   string        `json:"greeting_mode"`
+	Note           string        `json:"note"` //本次修改说明，记录在版本中
+}
+
+// Apply 把请求内容写到角色上，不改动浏览量、点赞量和状态
+func (r SaveRoleReq) Apply(role *Role) {
+	role.Name = r.Name
+	role.Prompt = r.Prompt
+	role.ImageUrl = r.ImageUrl
+	role.Description = r.Description
+	role.Category = r.Category
+	role.Tags = r.Tags
+	role.Voice = r.Voice
+	role.VoiceSampleUrl = r.VoiceSampleUrl
+	role.Tools = r.Tools
+	role.Speech = r.Speech
+	role.Refusal = r.Refusal
+	role.Persona = r.Persona
+	role.GreetingMode = r.GreetingMode
 }
 
+type AdminRoleList struct {
+	Roles []Role `json:"roles"`
+}
+
+// RoleVersion 角色提示词的一个版本，保存完整快照和与上一版的差异
+type RoleVersion struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	RoleID    int       `json:"role_id" gorm:"uniqueIndex:uk_role_version"`
+	Version   int       `json:"version" gorm:"uniqueIndex:uk_role_version"`
+	Prompt    string    `json:"prompt" gorm:"type:text"`
+	Persona   Persona   `json:"persona" gorm:"serializer:json"`
+	Editor    string    `json:"editor"` //修改人 user_id
+	Note      string    `json:"note"`
+	Diff      string    `json:"diff" gorm:"type:text"` //按行的差异，"+ " 新增，"- " 删除
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RoleVersionList struct {
+	Versions []RoleVersion `json:"versions"`
+}
+
+// 角色可声明的工具
+const (
+	ToolCurrentTime     = "current_time"
+	ToolRollDice        = "roll_dice"
+	ToolKnowledgeLookup = "knowledge_lookup"
+	ToolEndConversation = "end_conversation"
+)
+
+// RoleTools 全部可声明的工具，新增工具时同时在 usecase.NewTurnTools 中实现
+var RoleTools = []string{ToolCurrentTime, ToolRollDice, ToolKnowledgeLookup, ToolEndConversation}
+
+// ErrUnknownTool 角色声明了不存在的工具
+var ErrUnknownTool = errors.New("unknown tool")
+
+// 开场方式
+const (
+	GreetingNone        = ""
+	GreetingFixed       = "fixed"        //说 Persona.Greeting
+	GreetingWelcomeBack = "welcome_back" //根据上次对话生成欢迎回来，没有历史时说 Persona.Greeting
+)
+
+// WelcomeBackPrompt 生成“欢迎回来”开场白的指令，后面接上次对话的内容
+const WelcomeBackPrompt = `用户刚刚重新连接语音对话。请以角色的口吻主动说一两句简短的开场白：欢迎用户回来，并自然地提到上次聊到的内容。只输出要说的话。
+上次对话的最后几句：
+`
+
 const VoicePromot = `
 你正在参与实时语音对话，用户只能听到纯语音。请遵守：
 只输出应说的句子，禁止任何旁白、舞台指示或动作描写（如 微笑、转身、轻声说 等）。
@@ -10,3 +10,38 @@ type TtsResponse struct {
 		Duration string `json:"duration"` // 音频时长(毫秒)
 	} `json:"addition"`
 }
+
+// 合成音频格式
+const (
+	TtsFormatMp3 = "mp3"
+	TtsFormatWav = "wav"
+	TtsFormatPcm = "pcm" //16kHz 16 位小端单声道
+)
+
+// TtsReq 合成一段文本，Response 为 url 时返回 OSS 地址，否则直接返回音频
+type TtsReq struct {
+	Text     string  `json:"text"`
+	Voice    string  `json:"voice"`
+	Speed    float64 `json:"speed"`
+	Format   string  `json:"format"`
+	Response string  `json:"response"` //audio 或 url，默认 audio
+}
+
+// TtsResult 合成结果，Key 为 OSS 中缓存的文件
+type TtsResult struct {
+	Key    string `json:"key"`
+	Url    string `json:"url"`
+	Format string `json:"format"`
+	Cached bool   `json:"cached"`
+}
+
+// TtsCacheStats 句子级 TTS 缓存的命中统计
+type TtsCacheStats struct {
+	MemoryHits int64   `json:"memory_hits"`
+	OssHits    int64   `json:"oss_hits"`
+	Misses     int64   `json:"misses"`
+	Skipped    int64   `json:"skipped"` //句子太长不走缓存
+	HitRate    float64 `json:"hit_rate"`
+	Entries    int     `json:"entries"`
+	Bytes      int     `json:"bytes"`
+}
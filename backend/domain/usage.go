@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// UsageRecord 一轮对话消耗的第三方接口用量，开场白也算一轮
+type UsageRecord struct {
+	ID               int       `json:"id" gorm:"primaryKey"`
+	UserID           string    `json:"user_id" gorm:"type:varchar(64);index:idx_usage_user_time"`
+	ConversationID   int       `json:"conversation_id"`
+	AsrMs            int64     `json:"asr_ms"` //识别的音频时长
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	TtsChars         int64     `json:"tts_chars"` //实际送去合成的字数，命中缓存的不算
+	TtsMs            int64     `json:"tts_ms"`
+	CreatedAt        time.Time `json:"created_at" gorm:"index:idx_usage_user_time"`
+}
+
+// UsageSummary 一段时间内的用量合计
+type UsageSummary struct {
+	Turns            int64 `json:"turns"`
+	AsrMs            int64 `json:"asr_ms"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TtsChars         int64 `json:"tts_chars"`
+	TtsMs            int64 `json:"tts_ms"`
+}
+
+func (s UsageSummary) Tokens() int64 {
+	return s.PromptTokens + s.CompletionTokens
+}
+
+// UsageLimit 用量上限，0 表示不限
+type UsageLimit struct {
+	Tokens     int64 `json:"tokens"`
+	AsrSeconds int64 `json:"asr_seconds"`
+	TtsChars   int64 `json:"tts_chars"`
+}
+
+// UsagePeriod 某个周期（自然日或自然月）的用量和上限
+type UsagePeriod struct {
+	Start time.Time    `json:"start"`
+	Used  UsageSummary `json:"used"`
+	Limit UsageLimit   `json:"limit"`
+}
+
+// Exceeded 返回已经用完的额度，没有用完时返回空
+func (p UsagePeriod) Exceeded() string {
+	switch {
+	case p.Limit.Tokens > 0 && p.Used.Tokens() >= p.Limit.Tokens:
+		return "tokens"
+	case p.Limit.AsrSeconds > 0 && p.Used.AsrMs >= p.Limit.AsrSeconds*1000:
+		return "asr_seconds"
+	case p.Limit.TtsChars > 0 && p.Used.TtsChars >= p.Limit.TtsChars:
+		return "tts_chars"
+	}
+	return ""
+}
+
+type UserUsage struct {
+	Daily   UsagePeriod `json:"daily"`
+	Monthly UsagePeriod `json:"monthly"`
+}
+
+// QuotaError 日或月额度已用完
+type QuotaError struct {
+	Period string //daily 或 monthly
+	Limit  string
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s %s quota exceeded", e.Period, e.Limit)
+}
+
+// Exceeded 先检查日额度再检查月额度
+func (u UserUsage) Exceeded() error {
+	if limit := u.Daily.Exceeded(); limit != "" {
+		return &QuotaError{Period: "daily", Limit: limit}
+	}
+	if limit := u.Monthly.Exceeded(); limit != "" {
+		return &QuotaError{Period: "monthly", Limit: limit}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUsagePeriodExceeded(t *testing.T) {
+	cases := []struct {
+		name  string
+		used  UsageSummary
+		limit UsageLimit
+		want  string
+	}{
+		{"no limit", UsageSummary{PromptTokens: 1 << 40}, UsageLimit{}, ""},
+		{"under", UsageSummary{PromptTokens: 50, CompletionTokens: 49}, UsageLimit{Tokens: 100}, ""},
+		{"tokens reached", UsageSummary{PromptTokens: 50, CompletionTokens: 50}, UsageLimit{Tokens: 100}, "tokens"},
+		{"asr in ms", UsageSummary{AsrMs: 59999}, UsageLimit{AsrSeconds: 60}, ""},
+		{"asr reached", UsageSummary{AsrMs: 60000}, UsageLimit{AsrSeconds: 60}, "asr_seconds"},
+		{"tts reached", UsageSummary{TtsChars: 10}, UsageLimit{TtsChars: 10}, "tts_chars"},
+		{"tokens first", UsageSummary{PromptTokens: 10, TtsChars: 10}, UsageLimit{Tokens: 10, TtsChars: 10}, "tokens"},
+	}
+	for _, c := range cases {
+		if got := (UsagePeriod{Used: c.used, Limit: c.limit}).Exceeded(); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestUserUsageExceeded(t *testing.T) {
+	full := UsagePeriod{Used: UsageSummary{TtsChars: 10}, Limit: UsageLimit{TtsChars: 10}}
+	fullTokens := UsagePeriod{Used: UsageSummary{PromptTokens: 10}, Limit: UsageLimit{Tokens: 10}}
+	cases := []struct {
+		name  string
+		usage UserUsage
+		want  *QuotaError
+	}{
+		{"none", UserUsage{}, nil},
+		{"daily", UserUsage{Daily: full}, &QuotaError{Period: "daily", Limit: "tts_chars"}},
+		{"monthly", UserUsage{Monthly: fullTokens}, &QuotaError{Period: "monthly", Limit: "tokens"}},
+		{"daily first", UserUsage{Daily: full, Monthly: fullTokens}, &QuotaError{Period: "daily", Limit: "tts_chars"}},
+	}
+	for _, c := range cases {
+		err := c.usage.Exceeded()
+		if c.want == nil {
+			if err != nil {
+				t.Errorf("%s: got %v, want nil", c.name, err)
+			}
+			continue
+		}
+		var qe *QuotaError
+		if !errors.As(err, &qe) || *qe != *c.want {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestAsrResponseDurationMs(t *testing.T) {
+	withDuration := func(d string) *AsrResponse {
+		r := &AsrResponse{}
+		r.Data.Result.Additions = map[string]string{"duration": d}
+		return r
+	}
+	cases := []struct {
+		name string
+		resp *AsrResponse
+		want int64
+	}{
+		{"nil", nil, 0},
+		{"no additions", &AsrResponse{}, 0},
+		{"duration", withDuration("9336"), 9336},
+		{"invalid", withDuration("9.3s"), 0},
+	}
+	for _, c := range cases {
+		if got := c.resp.DurationMs(); got != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, got, c.want)
+		}
+	}
+}
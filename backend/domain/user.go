@@ -1,21 +1,178 @@
 package domain
 
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
 type User struct {
-	ID         string `json:"id"`
-	Name       string `json:"name"`
+	ID         string `json:"id" gorm:"primaryKey;type:varchar(64)"` //注册时生成的 UUID
+	Name       string `json:"name" gorm:"type:varchar(64);uniqueIndex:uk_user_name"`
 	Password   string `json:"password"`
-	CreateTime int64  `json:"create_time"`
+	CreateTime int64  `json:"create_time"`                               //注册时间，unix 秒
+	Role       string `json:"role" gorm:"type:varchar(16);default:user"` //账号角色 user/editor/admin
+	//个人资料
+	Nickname   string  `json:"nickname" gorm:"type:varchar(64)"`
+	Avatar     string  `json:"avatar" gorm:"type:varchar(255)"` //OSS key
+	Language   string  `json:"language" gorm:"type:varchar(16)"`
+	VoiceSpeed float64 `json:"voice_speed" gorm:"default:1"` //语音对话的默认语速
+}
+
+// UserProfile 返回给用户的资料，不含密码
+type UserProfile struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Nickname   string  `json:"nickname"`
+	Avatar     string  `json:"avatar"`
+	AvatarUrl  string  `json:"avatar_url,omitempty"`
+	Language   string  `json:"language"`
+	VoiceSpeed float64 `json:"voice_speed"`
+	Role       string  `json:"role"`
+	CreateTime int64   `json:"create_time"`
+}
+
+func (u User) Profile() UserProfile {
+	return UserProfile{
+		ID:         u.ID,
+		Name:       u.Name,
+		Nickname:   u.Nickname,
+		Avatar:     u.Avatar,
+		Language:   u.Language,
+		VoiceSpeed: u.VoiceSpeed,
+		Role:       u.Role,
+		CreateTime: u.CreateTime,
+	}
+}
+
+// UpdateProfileReq 只修改传了的字段；avatar 为 /v1/me/roles/avatar 上传后返回的 key
+type UpdateProfileReq struct {
+	Nickname   *string  `json:"nickname"`
+	Avatar     *string  `json:"avatar"`
+	Language   *string  `json:"language"`
+	VoiceSpeed *float64 `json:"voice_speed"`
+}
+
+var languageRe = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z]{2,4})?$`)
+
+// Validate 检查字段并返回要更新的列，userID 用于检查头像是否为本人上传
+func (r UpdateProfileReq) Validate(userID string) (map[string]any, error) {
+	updates := map[string]any{}
+	var errs []error
+	if r.Nickname != nil {
+		nickname := strings.TrimSpace(*r.Nickname)
+		if utf8.RuneCountInString(nickname) > 32 {
+			errs = append(errs, errors.New("nickname exceeds 32 characters"))
+		}
+		updates["nickname"] = nickname
+	}
+	if r.Avatar != nil {
+		if *r.Avatar != "" && !strings.HasPrefix(*r.Avatar, AvatarPrefix(userID)) {
+			errs = append(errs, errors.New("avatar must be uploaded by the current user"))
+		}
+		updates["avatar"] = *r.Avatar
+	}
+	if r.Language != nil {
+		if *r.Language != "" && !languageRe.MatchString(*r.Language) {
+			errs = append(errs, fmt.Errorf("invalid language %q", *r.Language))
+		}
+		updates["language"] = *r.Language
+	}
+	if r.VoiceSpeed != nil {
+		if *r.VoiceSpeed < 0.5 || *r.VoiceSpeed > 2.0 {
+			errs = append(errs, errors.New("voice_speed must be between 0.5 and 2.0"))
+		}
+		updates["voice_speed"] = *r.VoiceSpeed
+	}
+	return updates, errors.Join(errs...)
+}
+
+// AvatarPrefix 用户上传的头像都保存在这个 OSS 目录下
+func AvatarPrefix(userID string) string {
+	return "avatars/" + userID + "/"
+}
+
+type ChangePasswordReq struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
 }
 
+// DeleteAccountReq 删除账号前需要再次输入密码
+type DeleteAccountReq struct {
+	Password string `json:"password"`
+}
+
+// 密码长度限制，bcrypt 只使用前 72 字节
+const (
+	PasswordMinLen = 8
+	PasswordMaxLen = 72
+)
+
 type CreateUserReq struct {
 	Name     string `json:"name"`
 	Password string `json:"password"`
 }
 
+var userNameRe = regexp.MustCompile(`^[\p{L}\p{N}_]{3,32}$`)
+
+// Validate 用户名 3-32 个字母、数字、下划线或汉字，密码见 ValidatePassword
+func (r CreateUserReq) Validate() error {
+	var errs []error
+	if !userNameRe.MatchString(r.Name) {
+		errs = append(errs, errors.New("name must be 3-32 letters, digits or underscores"))
+	}
+	if err := ValidatePassword(r.Password); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// ValidatePassword 密码至少 PasswordMinLen 位，同时包含字母和数字
+func ValidatePassword(p string) error {
+	if len(p) < PasswordMinLen || len(p) > PasswordMaxLen {
+		return fmt.Errorf("password must be %d-%d characters", PasswordMinLen, PasswordMaxLen)
+	}
+	var letter, digit bool
+	for _, c := range p {
+		switch {
+		case unicode.IsLetter(c):
+			letter = true
+		case unicode.IsDigit(c):
+			digit = true
+		}
+	}
+	if !letter || !digit {
+		return errors.New("password must contain both letters and digits")
+	}
+	return nil
+}
+
 type LoginReq struct {
 	Name     string `json:"name"`
 	Password string `json:"password"`
 }
 type LoginResp struct {
-	Token string `json:"token"`
+	Token        string    `json:"token"` //access token，放在 Authorization 头中
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"` //只能使用一次，刷新后返回新的
+}
+
+type RefreshReq struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken 服务端保存的 refresh token。同一次登录刷新出的 token 属于同一个 Family，
+// 已被轮换的 token 再次使用时视为泄露，整个 Family 作废
+type RefreshToken struct {
+	Hash       string     `json:"-" gorm:"primaryKey;type:varchar(64)"` //token 的 sha256
+	UserID     string     `json:"user_id" gorm:"type:varchar(64);index"`
+	FamilyID   string     `json:"family_id" gorm:"type:varchar(64);index"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy string     `json:"-" gorm:"type:varchar(64)"`
+	CreatedAt  time.Time  `json:"created_at"`
 }
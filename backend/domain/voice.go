@@ -0,0 +1,29 @@
+package domain
+
+// Voice 语音服务提供的音色
+type Voice struct {
+	Name      string `json:"voice_name"`
+	Type      string `json:"voice_type"` //合成时使用，eg：qiniu_zh_female_tmjxxy
+	SampleUrl string `json:"url"`        //服务商提供的试听地址
+	Category  string `json:"category"`
+	//由 voice_type 推断，无法判断时为空
+	Language string `json:"language"` //eg：zh、en
+	Gender   string `json:"gender"`   //female 或 male
+}
+
+type VoiceList struct {
+	Voices []Voice `json:"voices"`
+}
+
+// VoiceQuery 音色列表过滤条件
+type VoiceQuery struct {
+	Language string `query:"language"`
+	Gender   string `query:"gender"`
+	Category string `query:"category"`
+}
+
+// VoicePreview 音色试听片段
+type VoicePreview struct {
+	VoiceType string `json:"voice_type"`
+	Url       string `json:"url"`
+}
@@ -9,37 +9,43 @@ import (
 type MsgType int
 
 const (
-	MsgTypeIntrupt MsgType = iota // 客户端打断（interrupt）
-	MsgTypeTranslate              // 服务端翻译的中文（通用文本展示 / 也可用于 ASR 文本）
-	MsgTypeState                  // 状态变更（v 审态）
-	MsgTypeAsrResult              // ASR 结果（详细结构）
-	MsgTypeTtsStart               // TTS 开始
-	MsgTypeTtsChunk               // TTS 二进制包提示（元信息，实际 audio 通过 BinaryMessage 发送）
-	MsgTypeTtsEnd                 // TTS 完成
-	MsgTypeError                  // 错误消息
+	MsgTypeIntrupt      MsgType = iota // 客户端打断（interrupt）
+	MsgTypeTranslate                   // 服务端翻译的中文（通用文本展示 / 也可用于 ASR 文本）
+	MsgTypeState                       // 状态变更（v 审态）
+	MsgTypeAsrResult                   // ASR 结果（详细结构）
+	MsgTypeTtsStart                    // TTS 开始
+	MsgTypeTtsChunk                    // TTS 二进制包提示（元信息，实际 audio 通过 BinaryMessage 发送）
+	MsgTypeTtsEnd                      // TTS 完成
+	MsgTypeError                       // 错误消息
+	MsgTypeEnd                         // 角色结束对话，服务端随后关闭连接
+	MsgTypeConversation                // 本次语音会话使用的对话线程，连接建立后发送
 )
 
 // 为了可读性，序列化时转成字符串
 var msgTypeName = map[MsgType]string{
-	MsgTypeIntrupt:  "intrupt",
-	MsgTypeTranslate: "translate",
-	MsgTypeState:    "state",
-	MsgTypeAsrResult: "asr_result",
-	MsgTypeTtsStart: "tts_start",
-	MsgTypeTtsChunk: "tts_chunk",
-	MsgTypeTtsEnd:   "tts_end",
-	MsgTypeError:    "error",
+	MsgTypeIntrupt:      "intrupt",
+	MsgTypeTranslate:    "translate",
+	MsgTypeState:        "state",
+	MsgTypeAsrResult:    "asr_result",
+	MsgTypeTtsStart:     "tts_start",
+	MsgTypeTtsChunk:     "tts_chunk",
+	MsgTypeTtsEnd:       "tts_end",
+	MsgTypeError:        "error",
+	MsgTypeEnd:          "end",
+	MsgTypeConversation: "conversation",
 }
 
 var msgTypeValue = map[string]MsgType{
-	"intrupt":    MsgTypeIntrupt,
-	"translate":  MsgTypeTranslate,
-	"state":      MsgTypeState,
-	"asr_result": MsgTypeAsrResult,
-	"tts_start":  MsgTypeTtsStart,
-	"tts_chunk":  MsgTypeTtsChunk,
-	"tts_end":    MsgTypeTtsEnd,
-	"error":      MsgTypeError,
+	"intrupt":      MsgTypeIntrupt,
+	"translate":    MsgTypeTranslate,
+	"state":        MsgTypeState,
+	"asr_result":   MsgTypeAsrResult,
+	"tts_start":    MsgTypeTtsStart,
+	"tts_chunk":    MsgTypeTtsChunk,
+	"tts_end":      MsgTypeTtsEnd,
+	"error":        MsgTypeError,
+	"end":          MsgTypeEnd,
+	"conversation": MsgTypeConversation,
 }
 
 // MarshalJSON 把枚举变成字符串
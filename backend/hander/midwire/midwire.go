@@ -1,23 +1,51 @@
 package midwire
 
 import (
+	"demo/domain"
+	"demo/pkg/token"
+	"errors"
 	"net/http"
+	"slices"
 
 	"github.com/golang-jwt/jwt/v5"
 	echoMiddleware "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 )
 
+// keys 验证 access token 的密钥，启动时由 Init 设置
+var keys *token.KeySet
+
+// Init 设置验证 token 用的密钥，需在开始处理请求前调用
+func Init(k *token.KeySet) {
+	keys = k
+}
+
+func keyFunc(t *jwt.Token) (any, error) {
+	if keys == nil {
+		return nil, errors.New("jwt keys not initialized")
+	}
+	return keys.Keyfunc(t)
+}
+
 // 从token中获取user_id
 //
 //	func Authorization(c echo.Context) error {
 //		return Mid(c)
 //	}
 func Mid(next echo.HandlerFunc) echo.HandlerFunc {
+	return auth("header:Authorization", next)
+}
+
+// WsMid 浏览器建立 WebSocket 时不能设置请求头，token 也可以放在 query 参数 token 中
+func WsMid(next echo.HandlerFunc) echo.HandlerFunc {
+	return auth("header:Authorization,query:token", next)
+}
+
+func auth(lookup string, next echo.HandlerFunc) echo.HandlerFunc {
 	jwtMiddleware := echoMiddleware.WithConfig(echoMiddleware.Config{
-		SigningKey:  []byte("secret"),
-		TokenLookup: "header:Authorization", // 也可以 query:token 等
-		ContextKey:  "user",                 // 默认就是 user
+		KeyFunc:     keyFunc,
+		TokenLookup: lookup,
+		ContextKey:  "user", // 默认就是 user
 		ErrorHandler: func(c echo.Context, err error) error {
 			return c.JSON(http.StatusUnauthorized, map[string]string{"msg": "invalid token" + err.Error()})
 		},
@@ -39,6 +67,59 @@ func Mid(next echo.HandlerFunc) echo.HandlerFunc {
 		}
 
 		c.Set("user_id", userId)
+		role, _ := claims["role"].(string)
+		c.Set("role", role)
+		var perms []string
+		if list, ok := claims["perms"].([]any); ok {
+			for _, p := range list {
+				if s, ok := p.(string); ok {
+					perms = append(perms, s)
+				}
+			}
+		}
+		c.Set("perms", perms)
 		return next(c)
 	})
 }
+
+// UserID 返回 Mid 写入的当前用户 id
+func UserID(c echo.Context) string {
+	id, _ := c.Get("user_id").(string)
+	return id
+}
+
+// Role 返回 token 中的账号角色
+func Role(c echo.Context) string {
+	role, _ := c.Get("role").(string)
+	return role
+}
+
+// Can 当前用户的 token 是否带有权限 perm
+func Can(c echo.Context, perm string) bool {
+	perms, _ := c.Get("perms").([]string)
+	return slices.Contains(perms, perm)
+}
+
+// Member 只允许注册用户访问，游客需要先注册，需放在 Mid 之后使用
+func Member(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if Role(c) == domain.AccountGuest {
+			return c.JSON(http.StatusForbidden, map[string]string{"msg": "register to use this feature"})
+		}
+		return next(c)
+	}
+}
+
+// Require 只允许拥有全部指定权限的用户访问，需放在 Mid 之后使用
+func Require(perms ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			for _, p := range perms {
+				if !Can(ctx, p) {
+					return ctx.JSON(http.StatusForbidden, map[string]string{"msg": "permission denied: " + p})
+				}
+			}
+			return next(ctx)
+		}
+	}
+}
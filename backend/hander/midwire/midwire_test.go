@@ -0,0 +1,65 @@
+package midwire
+
+import (
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/pkg/token"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequire(t *testing.T) {
+	c := &config.Config{Jwt: config.JwtConfig{Keys: map[string]string{"k": "secret"}, CurrentKid: "k", AccessTTL: 60}}
+	Init(token.NewKeySet(log.NewLogger(c), c))
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, Mid, Require(domain.PermModerationView))
+
+	for role, want := range map[string]int{
+		domain.AccountUser:   http.StatusForbidden,
+		domain.AccountEditor: http.StatusForbidden,
+		domain.AccountAdmin:  http.StatusOK,
+	} {
+		tok, _, err := keys.AccessToken("u1", role, domain.Permissions(role))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", tok)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: got %d, want %d", role, rec.Code, want)
+		}
+	}
+}
+
+func TestGuest(t *testing.T) {
+	c := &config.Config{Jwt: config.JwtConfig{Keys: map[string]string{"k": "secret"}, CurrentKid: "k", AccessTTL: 60}}
+	Init(token.NewKeySet(log.NewLogger(c), c))
+	tok, _, err := keys.AccessToken("g1", domain.AccountGuest, domain.Permissions(domain.AccountGuest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.String(http.StatusOK, UserID(c)) }
+	e.GET("/ws", ok, WsMid)
+	e.GET("/member", ok, WsMid, Member)
+
+	for path, want := range map[string]int{
+		"/ws?token=" + tok:     http.StatusOK,
+		"/member?token=" + tok: http.StatusForbidden,
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: got %d, want %d", path[:strings.Index(path, "?")], rec.Code, want)
+		}
+	}
+}
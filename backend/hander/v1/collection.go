@@ -0,0 +1,138 @@
+package V1
+
+import (
+	"demo/domain"
+	"demo/hander"
+	"demo/hander/midwire"
+	"demo/pkg/log"
+	"demo/serve"
+	"demo/usecase"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type CollectionHander struct {
+	*hander.BaseHandler
+
+	log        *log.Logger
+	collection *usecase.CollectionUsecase
+}
+
+func NewCollectionHander(s *serve.HttpServer, log *log.Logger, base *hander.BaseHandler, collection *usecase.CollectionUsecase) *CollectionHander {
+	h := &CollectionHander{
+		BaseHandler: base,
+		log:         log.WithModule("CollectionHander"),
+		collection:  collection,
+	}
+	s.Echo.POST("/v1/roles/:id/like", h.Like, midwire.Mid)
+	s.Echo.DELETE("/v1/roles/:id/like", h.Unlike, midwire.Mid)
+	s.Echo.POST("/v1/roles/:id/favorite", h.AddFavorite, midwire.Mid)
+	s.Echo.DELETE("/v1/roles/:id/favorite", h.RemoveFavorite, midwire.Mid)
+	s.Echo.GET("/v1/me/favorites", h.ListFavorites, midwire.Mid)
+	s.Echo.GET("/v1/me/recent-roles", h.ListRecentRoles, midwire.Mid)
+	return h
+}
+
+// Like godoc
+// @Summary Like a role
+// @Description Liking twice has no effect
+// @Tags Collection
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} domain.LikeResp
+// @Router /v1/roles/{id}/like [post]
+func (h *CollectionHander) Like(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	resp, err := h.collection.Like(c.Request().Context(), midwire.UserID(c), roleID)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to like role", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// Unlike godoc
+// @Summary Remove a like from a role
+// @Tags Collection
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} domain.LikeResp
+// @Router /v1/roles/{id}/like [delete]
+func (h *CollectionHander) Unlike(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	resp, err := h.collection.Unlike(c.Request().Context(), midwire.UserID(c), roleID)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to unlike role", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// AddFavorite godoc
+// @Summary Add a role to favorites
+// @Tags Collection
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} domain.FavoriteResp
+// @Router /v1/roles/{id}/favorite [post]
+func (h *CollectionHander) AddFavorite(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	if err := h.collection.AddFavorite(c.Request().Context(), midwire.UserID(c), roleID); err != nil {
+		return h.NewResponseWithError(c, "Failed to add favorite", err)
+	}
+	return h.NewResponseWithData(c, domain.FavoriteResp{Favorited: true})
+}
+
+// RemoveFavorite godoc
+// @Summary Remove a role from favorites
+// @Tags Collection
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} domain.FavoriteResp
+// @Router /v1/roles/{id}/favorite [delete]
+func (h *CollectionHander) RemoveFavorite(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	if err := h.collection.RemoveFavorite(c.Request().Context(), midwire.UserID(c), roleID); err != nil {
+		return h.NewResponseWithError(c, "Failed to remove favorite", err)
+	}
+	return h.NewResponseWithData(c, domain.FavoriteResp{Favorited: false})
+}
+
+// ListFavorites godoc
+// @Summary List favorite roles of the current user
+// @Tags Collection
+// @Produce json
+// @Success 200 {object} domain.RoleList
+// @Router /v1/me/favorites [get]
+func (h *CollectionHander) ListFavorites(c echo.Context) error {
+	roles, err := h.collection.ListFavorites(c.Request().Context(), midwire.UserID(c))
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to list favorites", err)
+	}
+	return h.NewResponseWithData(c, roles)
+}
+
+// ListRecentRoles godoc
+// @Summary List roles the current user chatted with recently
+// @Tags Collection
+// @Produce json
+// @Success 200 {object} domain.RoleList
+// @Router /v1/me/recent-roles [get]
+func (h *CollectionHander) ListRecentRoles(c echo.Context) error {
+	roles, err := h.collection.ListRecentRoles(c.Request().Context(), midwire.UserID(c))
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to list recent roles", err)
+	}
+	return h.NewResponseWithData(c, roles)
+}
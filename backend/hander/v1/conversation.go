@@ -0,0 +1,222 @@
+package V1
+
+import (
+	"demo/domain"
+	"demo/hander"
+	"demo/hander/midwire"
+	"demo/pkg/log"
+	"demo/serve"
+	"demo/usecase"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ConversationHander struct {
+	*hander.BaseHandler
+
+	log          *log.Logger
+	conversation *usecase.ConversationUsecase
+}
+
+func NewConversationHander(s *serve.HttpServer, log *log.Logger, base *hander.BaseHandler, conversation *usecase.ConversationUsecase) *ConversationHander {
+	h := &ConversationHander{
+		BaseHandler:  base,
+		log:          log.WithModule("ConversationHander"),
+		conversation: conversation,
+	}
+	g := s.Echo.Group("/v1/me/conversations", midwire.Mid)
+	g.GET("", h.ListConversations)
+	g.POST("", h.CreateConversation)
+	g.PATCH("/:id", h.UpdateConversation)
+	// 游客的次数按保存的消息计算，不允许游客删除
+	g.DELETE("/:id", h.DeleteConversation, midwire.Member)
+	g.GET("/:id/messages", h.ListMessages)
+	g.DELETE("/:id/messages", h.ClearConversation, midwire.Member)
+	g.DELETE("/:id/messages/:message_id", h.DeleteMessage, midwire.Member)
+	g.GET("/:id/export", h.Export)
+	s.Echo.GET("/v1/conversations/:id/export", h.Export, midwire.Mid)
+	return h
+}
+
+// ListConversations godoc
+// @Summary List conversations of the current user
+// @Description Most recently active first, with the last message of each conversation
+// @Tags Conversation
+// @Produce json
+// @Param role_id query int false "Only conversations with this role"
+// @Param archived query bool false "List archived conversations instead"
+// @Success 200 {object} domain.ConversationList
+// @Router /v1/me/conversations [get]
+func (h *ConversationHander) ListConversations(c echo.Context) error {
+	var q domain.ConversationQuery
+	if err := c.Bind(&q); err != nil {
+		return h.NewResponseWithError(c, "Invalid query", err)
+	}
+	list, err := h.conversation.ListConversations(c.Request().Context(), midwire.UserID(c), q)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to list conversations", err)
+	}
+	return h.NewResponseWithData(c, list)
+}
+
+// CreateConversation godoc
+// @Summary Start a new conversation with a role
+// @Description The title defaults to the first question. Pass the returned id as conversation_id when connecting /v1/ws
+// @Tags Conversation
+// @Accept json
+// @Produce json
+// @Param req body domain.CreateConversationReq true "Role id and optional title"
+// @Success 200 {object} domain.Conversation
+// @Router /v1/me/conversations [post]
+func (h *ConversationHander) CreateConversation(c echo.Context) error {
+	var req domain.CreateConversationReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "Invalid request", err)
+	}
+	conv, err := h.conversation.Create(c.Request().Context(), midwire.UserID(c), req)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to create conversation", err)
+	}
+	return h.NewResponseWithData(c, conv)
+}
+
+// UpdateConversation godoc
+// @Summary Rename or archive a conversation
+// @Tags Conversation
+// @Accept json
+// @Produce json
+// @Param id path int true "Conversation id"
+// @Param req body domain.UpdateConversationReq true "Fields to change"
+// @Success 200 {object} domain.Conversation
+// @Router /v1/me/conversations/{id} [patch]
+func (h *ConversationHander) UpdateConversation(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid conversation id", err)
+	}
+	var req domain.UpdateConversationReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "Invalid request", err)
+	}
+	conv, err := h.conversation.Update(c.Request().Context(), midwire.UserID(c), id, req)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to update conversation", err)
+	}
+	return h.NewResponseWithData(c, conv)
+}
+
+// DeleteConversation godoc
+// @Summary Delete a conversation and all its messages
+// @Tags Conversation
+// @Produce json
+// @Param id path int true "Conversation id"
+// @Success 200 {object} hander.Response
+// @Router /v1/me/conversations/{id} [delete]
+func (h *ConversationHander) DeleteConversation(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid conversation id", err)
+	}
+	if err := h.conversation.Delete(c.Request().Context(), midwire.UserID(c), id); err != nil {
+		return h.NewResponseWithError(c, "Failed to delete conversation", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// ListMessages godoc
+// @Summary List messages of a conversation
+// @Description Newest first, pass next_before_id of the previous page as before_id to get the next page. Tool call records are not included
+// @Tags Conversation
+// @Produce json
+// @Param id path int true "Conversation id"
+// @Param before_id query int false "Return messages with id smaller than this"
+// @Param limit query int false "Page size, default 20, max 100"
+// @Success 200 {object} domain.MessageList
+// @Router /v1/me/conversations/{id}/messages [get]
+func (h *ConversationHander) ListMessages(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid conversation id", err)
+	}
+	beforeID, _ := strconv.Atoi(c.QueryParam("before_id"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	list, err := h.conversation.ListMessages(c.Request().Context(), midwire.UserID(c), id, beforeID, limit)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to list messages", err)
+	}
+	return h.NewResponseWithData(c, list)
+}
+
+// DeleteMessage godoc
+// @Summary Delete a message
+// @Description Deleting an answer also deletes the tool call records of that turn
+// @Tags Conversation
+// @Produce json
+// @Param id path int true "Conversation id"
+// @Param message_id path int true "Message id"
+// @Success 200 {object} hander.Response
+// @Router /v1/me/conversations/{id}/messages/{message_id} [delete]
+func (h *ConversationHander) DeleteMessage(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid conversation id", err)
+	}
+	messageID, err := strconv.Atoi(c.Param("message_id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid message id", err)
+	}
+	if err := h.conversation.DeleteMessage(c.Request().Context(), midwire.UserID(c), id, messageID); err != nil {
+		return h.NewResponseWithError(c, "Failed to delete message", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// ClearConversation godoc
+// @Summary Clear a conversation
+// @Description Deletes all messages but keeps the conversation, the role will not remember anything
+// @Tags Conversation
+// @Produce json
+// @Param id path int true "Conversation id"
+// @Success 200 {object} domain.ClearConversationResp
+// @Router /v1/me/conversations/{id}/messages [delete]
+func (h *ConversationHander) ClearConversation(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid conversation id", err)
+	}
+	resp, err := h.conversation.ClearConversation(c.Request().Context(), midwire.UserID(c), id)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to clear conversation", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// Export godoc
+// @Summary Export a conversation
+// @Description Downloads the transcript as JSON or Markdown, or the recorded audio of both sides concatenated in order as one WAV or MP3 file
+// @Tags Conversation
+// @Produce json,text/markdown,audio/wav,audio/mpeg
+// @Param id path int true "Conversation id"
+// @Param format query string false "json (default), md, wav or mp3"
+// @Success 200 {object} domain.ConversationExport
+// @Router /v1/conversations/{id}/export [get]
+// @Router /v1/me/conversations/{id}/export [get]
+func (h *ConversationHander) Export(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid conversation id", err)
+	}
+	format := c.QueryParam("format")
+	if format == "" {
+		format = domain.ExportJSON
+	}
+	data, contentType, err := h.conversation.ExportFile(c.Request().Context(), midwire.UserID(c), id, format)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to export conversation", err)
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="conversation-%d.%s"`, id, format))
+	return c.Blob(http.StatusOK, contentType, data)
+}
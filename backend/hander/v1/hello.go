@@ -1,26 +1,43 @@
 package V1
 
 import (
+	"context"
+	"demo/domain"
+	"demo/hander"
+	"demo/hander/midwire"
+	"demo/pkg/log"
 	"demo/serve"
 	"demo/usecase"
-	"fmt"
+	"errors"
+	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
 type HelloHander struct {
-	l *usecase.LlmUsecase
+	log          *log.Logger
+	l            *usecase.LlmUsecase
+	conversation *usecase.ConversationUsecase
+	roleUsecase  usecase.RoleUsecase
+	usage        *usecase.UsageUsecase
+	moderation   *usecase.ModerationUsecase
 }
 
-func NewHelloHander(s *serve.HttpServer, l *usecase.LlmUsecase) *HelloHander {
+func NewHelloHander(s *serve.HttpServer, log *log.Logger, l *usecase.LlmUsecase, conversation *usecase.ConversationUsecase, roleUsecase usecase.RoleUsecase, usage *usecase.UsageUsecase, moderation *usecase.ModerationUsecase) *HelloHander {
 	h := &HelloHander{
-		l: l,
+		log:          log.WithModule("HelloHander"),
+		l:            l,
+		conversation: conversation,
+		roleUsecase:  roleUsecase,
+		usage:        usage,
+		moderation:   moderation,
 	}
 	//加载html文件
 	s.Echo.Static("/static", "static")
 	g := s.Echo.Group("/v1")
 	g.GET("/hello", Hello)
-	g.POST("/chat", h.Chat)
+	g.POST("/chat", h.Chat, midwire.Mid)
 	g.GET("/index", h.index)
 	return h
 }
@@ -37,33 +54,69 @@ func Hello(c echo.Context) error {
 
 type req struct {
 	Roleid   int    `json:"roleid"`
-	Userid   string `json:"userid"`
 	Question string `json:"question"`
 }
 
 // chat
 // @Summary chat with ai
-// @Description chat with ai
+// @Description chat with ai as the logged-in user. Questions and answers go through moderation and count against the usage quota
 // @Tags chat
 // @Accept  json
 // @Produce  json
+// @Failure 429 {object} hander.Response "Daily or monthly quota exceeded"
 func (h *HelloHander) Chat(c echo.Context) error {
 	var r req
 	if err := c.Bind(&r); err != nil {
 		return c.JSON(400, err)
 	}
-	messages, err := h.l.FormatMessage(c.Request().Context(), r.Userid, r.Roleid, r.Question)
+	ctx := c.Request().Context()
+	userID := midwire.UserID(c)
+	var quota *domain.QuotaError
+	if err := h.usage.Check(ctx, userID); errors.As(err, &quota) {
+		return c.JSON(http.StatusTooManyRequests, hander.Response{Message: quota.Error()})
+	}
+	role, err := h.roleUsecase.GetRole(ctx, r.Roleid)
+	if err != nil || !role.VisibleTo(userID) {
+		return c.JSON(404, hander.Response{Message: "role not found"})
+	}
+	// 接着用户与角色最近的对话聊
+	conv, err := h.conversation.Latest(ctx, userID, r.Roleid)
+	if err != nil {
+		return c.JSON(500, err)
+	}
+	// 提问被拦截时直接返回拒绝语，不经过 LLM
+	inRes := h.moderation.Check(ctx, userID, r.Roleid, domain.ModerationInput, r.Question)
+	if inRes.Action == domain.ModerationBlock {
+		return c.JSON(200, usecase.RefusalFor(role))
+	}
+	question := inRes.Text
+	messages, err := h.l.FormatMessage(ctx, conv, question)
 	if err != nil {
 		return c.JSON(500, err)
 	}
-	ch, err := h.l.Chat(c.Request().Context(), messages)
+	reply, err := h.l.Reply(ctx, messages)
 	if err != nil {
-		fmt.Println("chat err", err)
+		h.log.Error("chat failed", log.Int("role_id", r.Roleid), log.Error(err))
 		return c.JSON(500, err)
 	}
 	var res string
-	for c := range ch {
+	for c := range reply.Tokens {
 		res += c
 	}
+	reply.Usage.Wait(time.Second)
+	h.usage.Record(context.Background(), domain.UsageRecord{
+		UserID:           userID,
+		ConversationID:   conv.ID,
+		PromptTokens:     reply.Usage.Prompt.Load(),
+		CompletionTokens: reply.Usage.Completion.Load(),
+	})
+	if outRes := h.moderation.Check(ctx, userID, r.Roleid, domain.ModerationOutput, res); outRes.Action == domain.ModerationBlock {
+		res = usecase.RefusalFor(role)
+	} else {
+		res = outRes.Text
+	}
+	if err := h.l.SaveTurn(ctx, conv, question, res, reply, usecase.TurnAudio{}); err != nil {
+		h.log.Error("save turn failed", log.Int("conversation_id", conv.ID), log.Error(err))
+	}
 	return c.JSON(200, res)
 }
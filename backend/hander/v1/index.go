@@ -43,8 +43,37 @@ const index = `<!DOCTYPE html>
     const asrSpan = document.getElementById("asrText");
     const llmSpan = document.getElementById("llmText");
 
+    // 没有登录时以游客身份试用：保存 refresh token，每次开始前换一个新的 access token
+    async function accessToken() {
+      const saved = localStorage.getItem("refresh_token");
+      let body;
+      if (saved) {
+        const resp = await fetch("/v1/token/refresh", {
+          method: "POST",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify({ refresh_token: saved }),
+        });
+        body = await resp.json();
+      }
+      if (!body || !body.success) {
+        body = await (await fetch("/v1/guest", { method: "POST" })).json();
+      }
+      if (!body.success) {
+        throw new Error(body.message || "login failed");
+      }
+      localStorage.setItem("refresh_token", body.data.refresh_token);
+      return body.data.token;
+    }
+
     startBtn.onclick = async () => {
-      ws = new WebSocket("wss://204.141.218.207:8080/v1/ws");
+      let token;
+      try {
+        token = await accessToken();
+      } catch (e) {
+        statusDiv.textContent = "获取 token 失败: " + e.message;
+        return;
+      }
+      ws = new WebSocket("wss://204.141.218.207:8080/v1/ws?token=" + encodeURIComponent(token));
       ws.binaryType = "arraybuffer";
 
       ws.onopen = () => {
@@ -0,0 +1,122 @@
+package V1
+
+import (
+	"demo/domain"
+	"demo/hander"
+	"demo/hander/midwire"
+	"demo/pkg/log"
+	"demo/serve"
+	"demo/usecase"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type KnowledgeHander struct {
+	*hander.BaseHandler
+
+	log       *log.Logger
+	knowledge *usecase.KnowledgeUsecase
+}
+
+func NewKnowledgeHander(s *serve.HttpServer, log *log.Logger, base *hander.BaseHandler, knowledge *usecase.KnowledgeUsecase) *KnowledgeHander {
+	h := &KnowledgeHander{
+		BaseHandler: base,
+		log:         log.WithModule("KnowledgeHander"),
+		knowledge:   knowledge,
+	}
+	admin := midwire.Require(domain.PermKnowledgeManage)
+	s.Echo.POST("/v1/roles/:id/documents", h.Upload, midwire.Mid, admin)
+	s.Echo.GET("/v1/roles/:id/documents", h.List, midwire.Mid, admin)
+	s.Echo.DELETE("/v1/roles/:id/documents/:docId", h.Delete, midwire.Mid, admin)
+	s.Echo.GET("/v1/roles/:id/documents/search", h.Search, midwire.Mid, admin)
+	return h
+}
+
+// Upload godoc
+// @Summary Upload a knowledge document for a role
+// @Description Upload a .txt/.md document, it is chunked and indexed for retrieval
+// @Tags Knowledge
+// @Accept  multipart/form-data
+// @Produce json
+// @Param id path int true "Role id"
+// @Param file formData file true "Document to upload"
+// @Success 200 {object} domain.KnowledgeDocument
+// @Router /v1/roles/{id}/documents [post]
+func (h *KnowledgeHander) Upload(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid file", err)
+	}
+	doc, err := h.knowledge.Upload(c.Request().Context(), roleID, file)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to upload document", err)
+	}
+	return h.NewResponseWithData(c, doc)
+}
+
+// List godoc
+// @Summary List knowledge documents of a role
+// @Tags Knowledge
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} domain.KnowledgeDocumentList
+// @Router /v1/roles/{id}/documents [get]
+func (h *KnowledgeHander) List(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	docs, err := h.knowledge.ListDocuments(c.Request().Context(), roleID)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to list documents", err)
+	}
+	return h.NewResponseWithData(c, domain.KnowledgeDocumentList{Documents: docs})
+}
+
+// Delete godoc
+// @Summary Delete a knowledge document
+// @Tags Knowledge
+// @Produce json
+// @Param id path int true "Role id"
+// @Param docId path int true "Document id"
+// @Success 200 {object} string "Document deleted"
+// @Router /v1/roles/{id}/documents/{docId} [delete]
+func (h *KnowledgeHander) Delete(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	docID, err := strconv.Atoi(c.Param("docId"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid document id", err)
+	}
+	if err := h.knowledge.DeleteDocument(c.Request().Context(), roleID, docID); err != nil {
+		return h.NewResponseWithError(c, "Failed to delete document", err)
+	}
+	return h.NewResponseWithData(c, "Document deleted")
+}
+
+// Search godoc
+// @Summary Preview which passages a question retrieves
+// @Tags Knowledge
+// @Produce json
+// @Param id path int true "Role id"
+// @Param q query string true "Question"
+// @Success 200 {object} domain.KnowledgeSearchResp
+// @Router /v1/roles/{id}/documents/search [get]
+func (h *KnowledgeHander) Search(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	passages, err := h.knowledge.Search(c.Request().Context(), roleID, c.QueryParam("q"), 5)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to search documents", err)
+	}
+	return h.NewResponseWithData(c, domain.KnowledgeSearchResp{Passages: passages})
+}
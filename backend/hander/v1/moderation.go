@@ -0,0 +1,49 @@
+package V1
+
+import (
+	"demo/domain"
+	"demo/hander"
+	"demo/hander/midwire"
+	"demo/pkg/log"
+	"demo/serve"
+	"demo/usecase"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ModerationHander struct {
+	*hander.BaseHandler
+
+	log        *log.Logger
+	moderation *usecase.ModerationUsecase
+}
+
+func NewModerationHander(s *serve.HttpServer, log *log.Logger, base *hander.BaseHandler, moderation *usecase.ModerationUsecase) *ModerationHander {
+	h := &ModerationHander{
+		BaseHandler: base,
+		log:         log.WithModule("ModerationHander"),
+		moderation:  moderation,
+	}
+	s.Echo.GET("/v1/moderation/events", h.ListEvents, midwire.Mid, midwire.Require(domain.PermModerationView))
+	return h
+}
+
+// ListEvents godoc
+// @Summary List moderation events
+// @Description Newest first, pass the smallest id of the previous page as before_id to get the next page
+// @Tags Moderation
+// @Produce json
+// @Param before_id query int false "Return events with id smaller than this"
+// @Param limit query int false "Page size, default 20, max 100"
+// @Success 200 {object} domain.ModerationEventList
+// @Router /v1/moderation/events [get]
+func (h *ModerationHander) ListEvents(c echo.Context) error {
+	beforeID, _ := strconv.Atoi(c.QueryParam("before_id"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	events, err := h.moderation.ListEvents(c.Request().Context(), beforeID, limit)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to list moderation events", err)
+	}
+	return h.NewResponseWithData(c, domain.ModerationEventList{Events: events})
+}
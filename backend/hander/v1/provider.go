@@ -8,9 +8,18 @@ import (
 )
 
 type Handers struct {
-	Hello *HelloHander
-	User  *UserHander
-	Role  *RoleHander
+	Hello        *HelloHander
+	User         *UserHander
+	Role         *RoleHander
+	Knowledge    *KnowledgeHander
+	Moderation   *ModerationHander
+	RoleAdmin    *RoleAdminHander
+	Collection   *CollectionHander
+	UserRole     *UserRoleHander
+	Voice        *VoiceHander
+	Tts          *TtsHander
+	Conversation *ConversationHander
+	Usage        *UsageHander
 }
 
 var ProviderSet = wire.NewSet(
@@ -18,6 +27,15 @@ var ProviderSet = wire.NewSet(
 	NewHelloHander,
 	NewUserHander,
 	NewRoleHander,
+	NewKnowledgeHander,
+	NewModerationHander,
+	NewRoleAdminHander,
+	NewCollectionHander,
+	NewUserRoleHander,
+	NewVoiceHander,
+	NewTtsHander,
+	NewConversationHander,
+	NewUsageHander,
 	usecase.ProviderSet,
 
 	wire.Struct(new(Handers), "*"),
@@ -6,6 +6,7 @@ import (
 	"demo/pkg/log"
 	"demo/serve"
 	"demo/usecase"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
@@ -24,24 +25,51 @@ func NewRoleHander(s *serve.HttpServer, log *log.Logger, base *hander.BaseHandle
 		roleUsecase: roleUsecase,
 	}
 	s.Echo.GET("/v1/roles", r.ListRoles)
+	s.Echo.GET("/v1/roles/:id", r.GetRole)
 	return r
 }
 
-// ListRoles lists all roles
-// @Summary lists all roles
-// @Description lists all roles
+// ListRoles lists published roles
+// @Summary search roles
+// @Description full-text search over name, tags and description with filters, sorting and cursor pagination
 // @Tags Role
 // @Produce json
+// @Param q query string false "Search keyword"
+// @Param category query string false "Category"
+// @Param tag query string false "Tag"
+// @Param sort query string false "relevance, newest, views or likes"
+// @Param cursor query string false "next_cursor from the previous page. Relevance results page by offset, so roles added or edited between requests may repeat or be skipped"
+// @Param limit query int false "Page size, default 20, max 50"
 // @Success 200 {object} domain.RoleList
 // @Router /v1/roles [get]
 func (h *RoleHander) ListRoles(c echo.Context) error {
-	roles, err := h.roleUsecase.ListRoles(c.Request().Context())
+	var query domain.RoleQuery
+	if err := c.Bind(&query); err != nil {
+		return h.NewResponseWithError(c, "Invalid query", err)
+	}
+	roles, err := h.roleUsecase.ListRoles(c.Request().Context(), query)
 	if err != nil {
-		h.NewResponseWithError(c, "Failed to list roles", err)
-		return err
+		return h.NewResponseWithError(c, "Failed to list roles", err)
 	}
-	h.NewResponseWithData(c, domain.RoleList{Roles: roles})
-	return nil
+	return h.NewResponseWithData(c, roles)
 }
 
-
+// GetRole returns public details of a role
+// @Summary get role details
+// @Description returns public details of a published role and counts a view
+// @Tags Role
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} domain.RoleDetail
+// @Router /v1/roles/{id} [get]
+func (h *RoleHander) GetRole(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	role, err := h.roleUsecase.GetRoleDetail(c.Request().Context(), id)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to get role", err)
+	}
+	return h.NewResponseWithData(c, role)
+}
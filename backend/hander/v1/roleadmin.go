@@ -0,0 +1,268 @@
+package V1
+
+import (
+	"demo/domain"
+	"demo/hander"
+	"demo/hander/midwire"
+	"demo/pkg/log"
+	"demo/serve"
+	"demo/usecase"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RoleAdminHander 角色管理接口，需要 roles:manage 权限，审核需要 roles:review
+type RoleAdminHander struct {
+	*hander.BaseHandler
+
+	log         *log.Logger
+	roleUsecase usecase.RoleUsecase
+}
+
+func NewRoleAdminHander(s *serve.HttpServer, log *log.Logger, base *hander.BaseHandler, roleUsecase usecase.RoleUsecase) *RoleAdminHander {
+	h := &RoleAdminHander{
+		BaseHandler: base,
+		log:         log.WithModule("RoleAdminHander"),
+		roleUsecase: roleUsecase,
+	}
+	g := s.Echo.Group("/v1/admin/roles", midwire.Mid)
+	manage := midwire.Require(domain.PermRolesManage)
+	review := midwire.Require(domain.PermRolesReview)
+	g.GET("", h.List, manage)
+	g.POST("", h.Create, manage)
+	g.GET("/reviews", h.ListReviews, review)
+	g.GET("/:id", h.Get, manage)
+	g.PUT("/:id", h.Update, manage)
+	g.DELETE("/:id", h.Delete, manage)
+	g.POST("/:id/publish", h.Publish, manage)
+	g.POST("/:id/unpublish", h.Unpublish, manage)
+	g.GET("/:id/versions", h.ListVersions, manage)
+	g.POST("/:id/versions/:version/rollback", h.Rollback, manage)
+	g.POST("/:id/review", h.Review, review)
+	return h
+}
+
+// List godoc
+// @Summary List all roles including drafts
+// @Tags RoleAdmin
+// @Produce json
+// @Success 200 {object} domain.AdminRoleList
+// @Router /v1/admin/roles [get]
+func (h *RoleAdminHander) List(c echo.Context) error {
+	roles, err := h.roleUsecase.ListAllRoles(c.Request().Context())
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to list roles", err)
+	}
+	return h.NewResponseWithData(c, domain.AdminRoleList{Roles: roles})
+}
+
+// Create godoc
+// @Summary Create a role as draft
+// @Tags RoleAdmin
+// @Accept json
+// @Produce json
+// @Param role body domain.SaveRoleReq true "Role"
+// @Success 200 {object} domain.Role
+// @Failure 400 {object} hander.Response "Unknown tool"
+// @Router /v1/admin/roles [post]
+func (h *RoleAdminHander) Create(c echo.Context) error {
+	var req domain.SaveRoleReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "Invalid request", err)
+	}
+	role, err := h.roleUsecase.CreateRole(c.Request().Context(), req, midwire.UserID(c))
+	if err != nil {
+		return saveRoleError(h.BaseHandler, c, "Failed to create role", err)
+	}
+	return h.NewResponseWithData(c, role)
+}
+
+// Get godoc
+// @Summary Get a role with its full prompt
+// @Tags RoleAdmin
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} domain.Role
+// @Router /v1/admin/roles/{id} [get]
+func (h *RoleAdminHander) Get(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	role, err := h.roleUsecase.GetRole(c.Request().Context(), id)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to get role", err)
+	}
+	return h.NewResponseWithData(c, role)
+}
+
+// Update godoc
+// @Summary Update a role
+// @Description A new version is recorded when prompt or persona changes
+// @Tags RoleAdmin
+// @Accept json
+// @Produce json
+// @Param id path int true "Role id"
+// @Param role body domain.SaveRoleReq true "Role"
+// @Success 200 {object} domain.Role
+// @Failure 400 {object} hander.Response "Unknown tool"
+// @Router /v1/admin/roles/{id} [put]
+func (h *RoleAdminHander) Update(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	var req domain.SaveRoleReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "Invalid request", err)
+	}
+	role, err := h.roleUsecase.UpdateRole(c.Request().Context(), id, req, midwire.UserID(c))
+	if err != nil {
+		return saveRoleError(h.BaseHandler, c, "Failed to update role", err)
+	}
+	return h.NewResponseWithData(c, role)
+}
+
+// Delete godoc
+// @Summary Delete a role and its versions
+// @Tags RoleAdmin
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} string
+// @Router /v1/admin/roles/{id} [delete]
+func (h *RoleAdminHander) Delete(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	if err := h.roleUsecase.DeleteRole(c.Request().Context(), id); err != nil {
+		return h.NewResponseWithError(c, "Failed to delete role", err)
+	}
+	return h.NewResponseWithData(c, "Role deleted")
+}
+
+// Publish godoc
+// @Summary Publish a role so users can see it
+// @Tags RoleAdmin
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} string
+// @Router /v1/admin/roles/{id}/publish [post]
+func (h *RoleAdminHander) Publish(c echo.Context) error {
+	return h.setStatus(c, domain.RolePublished)
+}
+
+// Unpublish godoc
+// @Summary Move a role back to draft
+// @Tags RoleAdmin
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} string
+// @Router /v1/admin/roles/{id}/unpublish [post]
+func (h *RoleAdminHander) Unpublish(c echo.Context) error {
+	return h.setStatus(c, domain.RoleDraft)
+}
+
+func (h *RoleAdminHander) setStatus(c echo.Context, status string) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	if err := h.roleUsecase.SetRoleStatus(c.Request().Context(), id, status); err != nil {
+		return h.NewResponseWithError(c, "Failed to update role status", err)
+	}
+	return h.NewResponseWithData(c, status)
+}
+
+// ListVersions godoc
+// @Summary List prompt versions of a role, newest first
+// @Tags RoleAdmin
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} domain.RoleVersionList
+// @Router /v1/admin/roles/{id}/versions [get]
+func (h *RoleAdminHander) ListVersions(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	versions, err := h.roleUsecase.ListVersions(c.Request().Context(), id)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to list versions", err)
+	}
+	return h.NewResponseWithData(c, domain.RoleVersionList{Versions: versions})
+}
+
+// Rollback godoc
+// @Summary Roll back prompt and persona to a version
+// @Description The rollback is recorded as a new version
+// @Tags RoleAdmin
+// @Produce json
+// @Param id path int true "Role id"
+// @Param version path int true "Version to restore"
+// @Success 200 {object} domain.Role
+// @Router /v1/admin/roles/{id}/versions/{version}/rollback [post]
+func (h *RoleAdminHander) Rollback(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid version", err)
+	}
+	role, err := h.roleUsecase.RollbackRole(c.Request().Context(), id, version, midwire.UserID(c))
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to roll back role", err)
+	}
+	return h.NewResponseWithData(c, role)
+}
+
+// ListReviews godoc
+// @Summary List user-created public roles waiting for review
+// @Tags RoleAdmin
+// @Produce json
+// @Success 200 {object} domain.AdminRoleList
+// @Router /v1/admin/roles/reviews [get]
+func (h *RoleAdminHander) ListReviews(c echo.Context) error {
+	roles, err := h.roleUsecase.ListPendingReviews(c.Request().Context())
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to list pending reviews", err)
+	}
+	return h.NewResponseWithData(c, domain.AdminRoleList{Roles: roles})
+}
+
+// Review godoc
+// @Summary Approve or reject a user-created public role
+// @Tags RoleAdmin
+// @Accept json
+// @Produce json
+// @Param id path int true "Role id"
+// @Param review body domain.ReviewRoleReq true "Review result"
+// @Success 200 {object} string
+// @Router /v1/admin/roles/{id}/review [post]
+func (h *RoleAdminHander) Review(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	var req domain.ReviewRoleReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "Invalid request", err)
+	}
+	if err := h.roleUsecase.ReviewRole(c.Request().Context(), id, req); err != nil {
+		return h.NewResponseWithError(c, "Failed to review role", err)
+	}
+	return h.NewResponseWithData(c, "Role reviewed")
+}
+
+// saveRoleError 声明了不存在的工具时返回 400，其余错误同 NewResponseWithError
+func saveRoleError(h *hander.BaseHandler, c echo.Context, msg string, err error) error {
+	if errors.Is(err, domain.ErrUnknownTool) {
+		return c.JSON(http.StatusBadRequest, hander.Response{Message: msg + ": " + err.Error()})
+	}
+	return h.NewResponseWithError(c, msg, err)
+}
@@ -0,0 +1,74 @@
+package V1
+
+import (
+	"demo/domain"
+	"demo/hander"
+	"demo/hander/midwire"
+	"demo/pkg/log"
+	"demo/serve"
+	"demo/usecase"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type TtsHander struct {
+	*hander.BaseHandler
+
+	log   *log.Logger
+	tts   *usecase.TtsUsecase
+	cache *usecase.TtsCache
+}
+
+func NewTtsHander(s *serve.HttpServer, log *log.Logger, base *hander.BaseHandler, tts *usecase.TtsUsecase, cache *usecase.TtsCache) *TtsHander {
+	h := &TtsHander{
+		BaseHandler: base,
+		log:         log.WithModule("TtsHander"),
+		tts:         tts,
+		cache:       cache,
+	}
+	s.Echo.POST("/v1/tts", h.Synthesize, midwire.Mid, midwire.Member)
+	s.Echo.GET("/v1/admin/tts/cache", h.CacheStats, midwire.Mid, midwire.Require(domain.PermSystemView))
+	return h
+}
+
+// Synthesize godoc
+// @Summary Synthesize speech for a text
+// @Description Returns the audio, or its cached OSS url when response is "url". Results are cached by a hash of the inputs
+// @Tags Tts
+// @Accept json
+// @Produce json,audio/mpeg,audio/wav
+// @Param req body domain.TtsReq true "Text, voice, speed (0.5-2.0), format (mp3, wav or pcm) and response (audio or url)"
+// @Success 200 {object} domain.TtsResult
+// @Failure 429 {object} hander.Response "Daily or monthly quota exceeded"
+// @Router /v1/tts [post]
+func (h *TtsHander) Synthesize(c echo.Context) error {
+	var req domain.TtsReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "Invalid request", err)
+	}
+	result, audio, err := h.tts.Synthesize(c.Request().Context(), midwire.UserID(c), req)
+	var quota *domain.QuotaError
+	if errors.As(err, &quota) {
+		return c.JSON(http.StatusTooManyRequests, hander.Response{Message: quota.Error()})
+	}
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to synthesize speech", err)
+	}
+	if req.Response == "url" {
+		return h.NewResponseWithData(c, result)
+	}
+	return c.Blob(http.StatusOK, usecase.TtsContentType(result.Format), audio)
+}
+
+// CacheStats godoc
+// @Summary TTS sentence cache statistics
+// @Description Hit counts since the process started and the size of the in-memory cache
+// @Tags Tts
+// @Produce json
+// @Success 200 {object} domain.TtsCacheStats
+// @Router /v1/admin/tts/cache [get]
+func (h *TtsHander) CacheStats(c echo.Context) error {
+	return h.NewResponseWithData(c, h.cache.Stats())
+}
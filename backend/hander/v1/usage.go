@@ -0,0 +1,43 @@
+package V1
+
+import (
+	"demo/hander"
+	"demo/hander/midwire"
+	"demo/pkg/log"
+	"demo/serve"
+	"demo/usecase"
+
+	"github.com/labstack/echo/v4"
+)
+
+type UsageHander struct {
+	*hander.BaseHandler
+
+	log   *log.Logger
+	usage *usecase.UsageUsecase
+}
+
+func NewUsageHander(s *serve.HttpServer, log *log.Logger, base *hander.BaseHandler, usage *usecase.UsageUsecase) *UsageHander {
+	h := &UsageHander{
+		BaseHandler: base,
+		log:         log.WithModule("UsageHander"),
+		usage:       usage,
+	}
+	s.Echo.GET("/v1/me/usage", h.GetUsage, midwire.Mid)
+	return h
+}
+
+// GetUsage godoc
+// @Summary Get usage and quotas of the current user
+// @Description ASR audio, LLM tokens and TTS characters used today and this month. A limit of 0 means unlimited
+// @Tags Usage
+// @Produce json
+// @Success 200 {object} domain.UserUsage
+// @Router /v1/me/usage [get]
+func (h *UsageHander) GetUsage(c echo.Context) error {
+	usage, err := h.usage.Get(c.Request().Context(), midwire.UserID(c))
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to get usage", err)
+	}
+	return h.NewResponseWithData(c, usage)
+}
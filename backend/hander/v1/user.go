@@ -4,10 +4,13 @@ import (
 	_ "demo/docs"
 	"demo/domain"
 	"demo/hander"
+	"demo/hander/midwire"
 	"demo/pkg/log"
 	"demo/serve"
 	"demo/usecase"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
@@ -38,14 +41,24 @@ func NewUserHander(s *serve.HttpServer, base *hander.BaseHandler, logger *log.Lo
 	}
 	g.POST("/register", u.Register)
 	g.POST("/login", u.Login)
+	g.POST("/token/refresh", u.Refresh)
+	g.POST("/guest", u.Guest)
+	g.POST("/guest/claim", u.ClaimGuest, midwire.Mid)
+	g.POST("/logout", u.Logout, midwire.Mid)
+	g.POST("/logout/all", u.LogoutAll, midwire.Mid)
+	g.GET("/me", u.GetProfile, midwire.Mid)
+	g.PATCH("/me", u.UpdateProfile, midwire.Mid)
+	g.PUT("/me/password", u.ChangePassword, midwire.Mid)
+	g.DELETE("/me", u.DeleteAccount, midwire.Mid)
+	g.PUT("/admin/users/:id/role", u.SetRole, midwire.Mid, midwire.Require(domain.PermUsersManage))
 	g.POST("/upload", u.Upload)
-	g.GET("/ws", u.UpgradeToWS)
+	g.GET("/ws", u.UpgradeToWS, midwire.WsMid)
 	return u
 }
 
 // Register godoc
 // @Summary Register a new user
-// @Description Register a new user
+// @Description Name is 3-32 letters, digits or underscores. Password is 8-72 characters with both letters and digits
 // @Tags User
 // @Accept  json
 // @Produce json
@@ -75,13 +88,17 @@ func (u *UserHander) Register(c echo.Context) error {
 // @Param user body domain.LoginReq true "User data"
 // @Success 200 {object} domain.LoginResp "User logged in successfully"
 // @Failure 400 {object} string "Bad request"
+// @Failure 429 {object} hander.Response "Too many failed attempts for this account or IP"
 // @Router /v1/login [post]
 func (u *UserHander) Login(c echo.Context) error {
 	var req domain.LoginReq
 	if err := c.Bind(&req); err != nil {
 		return err
 	}
-	resp, err := u.usercase.Login(c.Request().Context(), &req)
+	resp, err := u.usercase.Login(c.Request().Context(), &req, c.RealIP())
+	if errors.Is(err, usecase.ErrLoginLocked) {
+		return c.JSON(http.StatusTooManyRequests, hander.Response{Message: err.Error()})
+	}
 	if err != nil {
 		u.NewResponseWithError(c, "Failed to login user", err)
 		return err
@@ -89,6 +106,210 @@ func (u *UserHander) Login(c echo.Context) error {
 	return u.NewResponseWithData(c, resp)
 }
 
+// Refresh godoc
+// @Summary Get a new access token
+// @Description The refresh token can be used once, the response contains a new one. Reusing an old refresh token signs out that login on all its tokens
+// @Tags User
+// @Accept  json
+// @Produce json
+// @Param req body domain.RefreshReq true "Refresh token"
+// @Success 200 {object} domain.LoginResp
+// @Router /v1/token/refresh [post]
+func (u *UserHander) Refresh(c echo.Context) error {
+	var req domain.RefreshReq
+	if err := c.Bind(&req); err != nil {
+		return u.NewResponseWithError(c, "Invalid request", err)
+	}
+	resp, err := u.usercase.Refresh(c.Request().Context(), &req)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, hander.Response{Message: "Failed to refresh token: " + err.Error()})
+	}
+	return u.NewResponseWithData(c, resp)
+}
+
+// Guest godoc
+// @Summary Start a guest session
+// @Description Returns tokens for an anonymous guest that can talk to roles for a limited number of turns without registering
+// @Tags User
+// @Produce json
+// @Success 200 {object} domain.LoginResp
+// @Failure 429 {object} hander.Response "Too many guest sessions from this IP"
+// @Router /v1/guest [post]
+func (u *UserHander) Guest(c echo.Context) error {
+	resp, err := u.usercase.Guest(c.Request().Context(), c.RealIP())
+	if errors.Is(err, usecase.ErrGuestLimited) {
+		return c.JSON(http.StatusTooManyRequests, hander.Response{Message: err.Error()})
+	}
+	if err != nil {
+		return u.NewResponseWithError(c, "Failed to create guest", err)
+	}
+	return u.NewResponseWithData(c, resp)
+}
+
+// ClaimGuest godoc
+// @Summary Register the current guest as a user
+// @Description Creates an account with the name and password, moves the guest's conversations to it and returns new tokens. The guest tokens stop working
+// @Tags User
+// @Accept  json
+// @Produce json
+// @Param user body domain.CreateUserReq true "User data"
+// @Success 200 {object} domain.LoginResp
+// @Router /v1/guest/claim [post]
+func (u *UserHander) ClaimGuest(c echo.Context) error {
+	var req domain.CreateUserReq
+	if err := c.Bind(&req); err != nil {
+		return u.NewResponseWithError(c, "Invalid request", err)
+	}
+	if midwire.Role(c) != domain.AccountGuest {
+		return c.JSON(http.StatusForbidden, hander.Response{Message: "only guests can claim an account"})
+	}
+	resp, err := u.usercase.ClaimGuest(c.Request().Context(), midwire.UserID(c), &req)
+	if err != nil {
+		return u.NewResponseWithError(c, "Failed to claim account", err)
+	}
+	return u.NewResponseWithData(c, resp)
+}
+
+// Logout godoc
+// @Summary Sign out the current login
+// @Description Revokes the refresh token. The access token stays valid until it expires
+// @Tags User
+// @Accept  json
+// @Produce json
+// @Param req body domain.RefreshReq true "Refresh token of this login"
+// @Success 200 {object} hander.Response
+// @Router /v1/logout [post]
+func (u *UserHander) Logout(c echo.Context) error {
+	var req domain.RefreshReq
+	if err := c.Bind(&req); err != nil {
+		return u.NewResponseWithError(c, "Invalid request", err)
+	}
+	if err := u.usercase.Logout(c.Request().Context(), midwire.UserID(c), &req); err != nil {
+		return u.NewResponseWithError(c, "Failed to logout", err)
+	}
+	return u.NewResponseWithData(c, nil)
+}
+
+// LogoutAll godoc
+// @Summary Sign out on all devices
+// @Description Revokes all refresh tokens of the current user
+// @Tags User
+// @Produce json
+// @Success 200 {object} hander.Response
+// @Router /v1/logout/all [post]
+func (u *UserHander) LogoutAll(c echo.Context) error {
+	if err := u.usercase.LogoutAll(c.Request().Context(), midwire.UserID(c)); err != nil {
+		return u.NewResponseWithError(c, "Failed to logout", err)
+	}
+	return u.NewResponseWithData(c, nil)
+}
+
+// GetProfile godoc
+// @Summary Get the profile of the current user
+// @Tags User
+// @Produce json
+// @Success 200 {object} domain.UserProfile
+// @Router /v1/me [get]
+func (u *UserHander) GetProfile(c echo.Context) error {
+	profile, err := u.usercase.GetProfile(c.Request().Context(), midwire.UserID(c))
+	if err != nil {
+		return u.NewResponseWithError(c, "Failed to get profile", err)
+	}
+	return u.NewResponseWithData(c, profile)
+}
+
+// UpdateProfile godoc
+// @Summary Update the profile of the current user
+// @Description Only the fields present are changed. Upload the avatar with /v1/me/roles/avatar first and pass the returned key
+// @Tags User
+// @Accept  json
+// @Produce json
+// @Param req body domain.UpdateProfileReq true "Nickname, avatar, language and default voice speed (0.5-2.0)"
+// @Success 200 {object} domain.UserProfile
+// @Router /v1/me [patch]
+func (u *UserHander) UpdateProfile(c echo.Context) error {
+	var req domain.UpdateProfileReq
+	if err := c.Bind(&req); err != nil {
+		return u.NewResponseWithError(c, "Invalid request", err)
+	}
+	profile, err := u.usercase.UpdateProfile(c.Request().Context(), midwire.UserID(c), req)
+	if err != nil {
+		return u.NewResponseWithError(c, "Failed to update profile", err)
+	}
+	return u.NewResponseWithData(c, profile)
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Requires the old password. Other devices need to sign in again
+// @Tags User
+// @Accept  json
+// @Produce json
+// @Param req body domain.ChangePasswordReq true "Old and new password"
+// @Success 200 {object} hander.Response
+// @Failure 429 {object} hander.Response "Too many wrong passwords for this account"
+// @Router /v1/me/password [put]
+func (u *UserHander) ChangePassword(c echo.Context) error {
+	var req domain.ChangePasswordReq
+	if err := c.Bind(&req); err != nil {
+		return u.NewResponseWithError(c, "Invalid request", err)
+	}
+	err := u.usercase.ChangePassword(c.Request().Context(), midwire.UserID(c), req)
+	if errors.Is(err, usecase.ErrLoginLocked) {
+		return c.JSON(http.StatusTooManyRequests, hander.Response{Message: err.Error()})
+	}
+	if err != nil {
+		return u.NewResponseWithError(c, "Failed to change password", err)
+	}
+	return u.NewResponseWithData(c, nil)
+}
+
+// SetRole godoc
+// @Summary Change the account role of a user
+// @Description Role is one of user, editor, admin. Takes effect when the user's access token is refreshed
+// @Tags User
+// @Accept  json
+// @Produce json
+// @Param id path string true "User id"
+// @Param req body domain.UpdateAccountRoleReq true "New role"
+// @Success 200 {object} hander.Response
+// @Router /v1/admin/users/{id}/role [put]
+func (u *UserHander) SetRole(c echo.Context) error {
+	var req domain.UpdateAccountRoleReq
+	if err := c.Bind(&req); err != nil {
+		return u.NewResponseWithError(c, "Invalid request", err)
+	}
+	if err := u.usercase.SetRole(c.Request().Context(), midwire.UserID(c), c.Param("id"), req); err != nil {
+		return u.NewResponseWithError(c, "Failed to change role", err)
+	}
+	return u.NewResponseWithData(c, nil)
+}
+
+// DeleteAccount godoc
+// @Summary Delete the current account
+// @Description Requires the password. Deletes conversations, recorded audio, likes, favorites and custom roles of the user
+// @Tags User
+// @Accept  json
+// @Produce json
+// @Param req body domain.DeleteAccountReq true "Password"
+// @Success 200 {object} hander.Response
+// @Failure 429 {object} hander.Response "Too many wrong passwords for this account"
+// @Router /v1/me [delete]
+func (u *UserHander) DeleteAccount(c echo.Context) error {
+	var req domain.DeleteAccountReq
+	if err := c.Bind(&req); err != nil {
+		return u.NewResponseWithError(c, "Invalid request", err)
+	}
+	err := u.usercase.DeleteAccount(c.Request().Context(), midwire.UserID(c), req)
+	if errors.Is(err, usecase.ErrLoginLocked) {
+		return c.JSON(http.StatusTooManyRequests, hander.Response{Message: err.Error()})
+	}
+	if err != nil {
+		return u.NewResponseWithError(c, "Failed to delete account", err)
+	}
+	return u.NewResponseWithData(c, nil)
+}
+
 // Upload godoc
 // @Summary Upload a file
 // @Description Upload a file
@@ -118,24 +339,25 @@ var upgrader = websocket.Upgrader{
 
 // UpgradeToWS godoc
 // @Summary 升级为 WebSocket 实时对话
-// @Description 握手成功后，客户端与服务端全双工通信
+// @Description 握手成功后，客户端与服务端全双工通信。不传 conversation_id 时继续与该角色最近的对话（没有时新建，要开启新对话先调用创建对话接口），传了则恢复该对话（角色以对话为准），服务端随后发送 conversation 消息
 // @Tags User
-// @Param roleid path int true "Role id"
+// @Param token query string false "Access token, browsers cannot set the Authorization header on WebSocket"
+// @Param role_id query int false "Role id, default 1"
+// @Param conversation_id query int false "Conversation to resume"
 // @Success 101 {string} string "Switching Protocols"
 // @Router /v1/ws [get]
 func (u *UserHander) UpgradeToWS(c echo.Context) error {
+	roleID, err := strconv.Atoi(c.QueryParam("role_id"))
+	if err != nil {
+		roleID = 1
+	}
+	conversationID, _ := strconv.Atoi(c.QueryParam("conversation_id"))
 	// Echo 内置助手，一行完成 HTTP/1.1 → 101 升级
 	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
 		return err
 	}
 	defer ws.Close()
-	//	userid, ok := c.Get("userid").(string)
-	//roleid, err := strconv.Atoi(c.Param("roleid"))
-	//if !ok || err != nil {
-	//	return errors.New("Invalid user id or role id")
-	//}
-	// 这里替换成你的「上下文聊天」逻辑即可
-	u.wsusecase.HanderWs2(ws, "1", 1)
+	u.wsusecase.HanderWs2(ws, midwire.UserID(c), roleID, conversationID)
 	return nil
 }
@@ -0,0 +1,136 @@
+package V1
+
+import (
+	"demo/domain"
+	"demo/hander"
+	"demo/hander/midwire"
+	"demo/pkg/log"
+	"demo/serve"
+	"demo/usecase"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// UserRoleHander 用户管理自己创建的角色
+type UserRoleHander struct {
+	*hander.BaseHandler
+
+	log      *log.Logger
+	userRole *usecase.UserRoleUsecase
+}
+
+func NewUserRoleHander(s *serve.HttpServer, log *log.Logger, base *hander.BaseHandler, userRole *usecase.UserRoleUsecase) *UserRoleHander {
+	h := &UserRoleHander{
+		BaseHandler: base,
+		log:         log.WithModule("UserRoleHander"),
+		userRole:    userRole,
+	}
+	g := s.Echo.Group("/v1/me/roles", midwire.Mid)
+	g.GET("", h.List)
+	g.POST("", h.Create, midwire.Member)
+	g.POST("/avatar", h.UploadAvatar, midwire.Member)
+	g.PUT("/:id", h.Update)
+	g.DELETE("/:id", h.Delete)
+	return h
+}
+
+// List godoc
+// @Summary List roles created by the current user
+// @Tags UserRole
+// @Produce json
+// @Success 200 {object} domain.AdminRoleList
+// @Router /v1/me/roles [get]
+func (h *UserRoleHander) List(c echo.Context) error {
+	roles, err := h.userRole.ListRoles(c.Request().Context(), midwire.UserID(c))
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to list roles", err)
+	}
+	return h.NewResponseWithData(c, roles)
+}
+
+// Create godoc
+// @Summary Create a custom role
+// @Description Private roles are usable by the creator only, public roles are listed after review
+// @Tags UserRole
+// @Accept json
+// @Produce json
+// @Param role body domain.UserRoleReq true "Role"
+// @Success 200 {object} domain.Role
+// @Failure 400 {object} hander.Response "Unknown tool"
+// @Router /v1/me/roles [post]
+func (h *UserRoleHander) Create(c echo.Context) error {
+	var req domain.UserRoleReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "Invalid request", err)
+	}
+	role, err := h.userRole.CreateRole(c.Request().Context(), midwire.UserID(c), req)
+	if err != nil {
+		return saveRoleError(h.BaseHandler, c, "Failed to create role", err)
+	}
+	return h.NewResponseWithData(c, role)
+}
+
+// Update godoc
+// @Summary Update a custom role
+// @Tags UserRole
+// @Accept json
+// @Produce json
+// @Param id path int true "Role id"
+// @Param role body domain.UserRoleReq true "Role"
+// @Success 200 {object} domain.Role
+// @Failure 400 {object} hander.Response "Unknown tool"
+// @Router /v1/me/roles/{id} [put]
+func (h *UserRoleHander) Update(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	var req domain.UserRoleReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "Invalid request", err)
+	}
+	role, err := h.userRole.UpdateRole(c.Request().Context(), midwire.UserID(c), id, req)
+	if err != nil {
+		return saveRoleError(h.BaseHandler, c, "Failed to update role", err)
+	}
+	return h.NewResponseWithData(c, role)
+}
+
+// Delete godoc
+// @Summary Delete a custom role
+// @Tags UserRole
+// @Produce json
+// @Param id path int true "Role id"
+// @Success 200 {object} string
+// @Router /v1/me/roles/{id} [delete]
+func (h *UserRoleHander) Delete(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid role id", err)
+	}
+	if err := h.userRole.DeleteRole(c.Request().Context(), midwire.UserID(c), id); err != nil {
+		return h.NewResponseWithError(c, "Failed to delete role", err)
+	}
+	return h.NewResponseWithData(c, "Role deleted")
+}
+
+// UploadAvatar godoc
+// @Summary Upload an avatar image for a custom role
+// @Tags UserRole
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Image, at most 2MB"
+// @Success 200 {object} domain.AvatarResp
+// @Router /v1/me/roles/avatar [post]
+func (h *UserRoleHander) UploadAvatar(c echo.Context) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return h.NewResponseWithError(c, "Invalid file", err)
+	}
+	url, err := h.userRole.UploadAvatar(c.Request().Context(), midwire.UserID(c), file)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to upload avatar", err)
+	}
+	return h.NewResponseWithData(c, domain.AvatarResp{ImageUrl: url})
+}
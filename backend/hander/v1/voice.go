@@ -0,0 +1,67 @@
+package V1
+
+import (
+	"demo/domain"
+	"demo/hander"
+	"demo/hander/midwire"
+	"demo/pkg/log"
+	"demo/serve"
+	"demo/usecase"
+
+	"github.com/labstack/echo/v4"
+)
+
+type VoiceHander struct {
+	*hander.BaseHandler
+
+	log   *log.Logger
+	voice *usecase.VoiceUsecase
+}
+
+func NewVoiceHander(s *serve.HttpServer, log *log.Logger, base *hander.BaseHandler, voice *usecase.VoiceUsecase) *VoiceHander {
+	h := &VoiceHander{
+		BaseHandler: base,
+		log:         log.WithModule("VoiceHander"),
+		voice:       voice,
+	}
+	s.Echo.GET("/v1/voices", h.List)
+	s.Echo.GET("/v1/voices/:type/preview", h.Preview, midwire.Mid)
+	return h
+}
+
+// List godoc
+// @Summary List available voices
+// @Tags Voice
+// @Produce json
+// @Param language query string false "Language, e.g. zh or en"
+// @Param gender query string false "female or male"
+// @Param category query string false "Category"
+// @Success 200 {object} domain.VoiceList
+// @Router /v1/voices [get]
+func (h *VoiceHander) List(c echo.Context) error {
+	var q domain.VoiceQuery
+	if err := c.Bind(&q); err != nil {
+		return h.NewResponseWithError(c, "Invalid query", err)
+	}
+	voices, err := h.voice.ListVoices(c.Request().Context(), q)
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to list voices", err)
+	}
+	return h.NewResponseWithData(c, domain.VoiceList{Voices: voices})
+}
+
+// Preview godoc
+// @Summary Get a short preview clip of a voice
+// @Description The clip is synthesized on first request and cached in OSS
+// @Tags Voice
+// @Produce json
+// @Param type path string true "Voice type"
+// @Success 200 {object} domain.VoicePreview
+// @Router /v1/voices/{type}/preview [get]
+func (h *VoiceHander) Preview(c echo.Context) error {
+	preview, err := h.voice.Preview(c.Request().Context(), c.Param("type"))
+	if err != nil {
+		return h.NewResponseWithError(c, "Failed to get voice preview", err)
+	}
+	return h.NewResponseWithData(c, preview)
+}
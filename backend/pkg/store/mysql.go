@@ -15,15 +15,33 @@ type MySQL struct {
 }
 
 func NewMySQL(config *config.Config) *MySQL {
-	db, err := gorm.Open(mysql.Open(config.MySQL.Dsn), &gorm.Config{})
+	// TranslateError 把唯一索引冲突等错误转换成 gorm.ErrDuplicatedKey
+	db, err := gorm.Open(mysql.Open(config.MySQL.Dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic(err)
 	}
 
+	// 早期注册的用户没有 id，唯一索引建立前补上（重名用户需要手动处理）
+	db.Exec("UPDATE users SET id = UUID() WHERE id IS NULL OR id = ''")
 	// 自动迁移表结构
 	db.AutoMigrate(domain.User{})
 	db.AutoMigrate(domain.Role{})
 	db.AutoMigrate(domain.ConversationMessage{})
+	db.AutoMigrate(domain.Conversation{})
+	db.AutoMigrate(domain.RefreshToken{})
+	db.AutoMigrate(domain.KnowledgeDocument{})
+	db.AutoMigrate(domain.KnowledgeChunk{})
+	db.AutoMigrate(domain.ModerationEvent{})
+	db.AutoMigrate(domain.RoleVersion{})
+	db.AutoMigrate(domain.UserRoleLike{})
+	db.AutoMigrate(domain.UserRoleFavorite{})
+	db.AutoMigrate(domain.UsageRecord{})
+	// 角色搜索用的全文索引（ngram 分词支持中文），HasIndex 查 information_schema，只在不存在时创建
+	if !db.Migrator().HasIndex(&domain.Role{}, "ft_role_search") {
+		if err := db.Exec("ALTER TABLE roles ADD FULLTEXT INDEX ft_role_search (name, tags, description) WITH PARSER ngram").Error; err != nil {
+			log.NewLogger(config).WithModule("MySQL").Error("Failed to create fulltext index: ", "ERROR: ", err.Error())
+		}
+	}
 	// 分割SQL语句并执行
 	sqlStatements := strings.Split(initSQL, ";")
 	for _, stmt := range sqlStatements {
@@ -40,6 +58,13 @@ func NewMySQL(config *config.Config) *MySQL {
 }
 
 const initSQL = `
+-- 历史消息没有对话线程：每个用户与角色的旧消息归入一个对话，已迁移过时不会再匹配到消息
+INSERT INTO conversations (user_id, role_id, title, archived, created_at, updated_at)
+SELECT user_id, role_id, '', false, MIN(time), MAX(time) FROM conversation_messages
+WHERE conversation_id IS NULL OR conversation_id = 0 GROUP BY user_id, role_id;
+UPDATE conversation_messages m SET conversation_id = (
+    SELECT MAX(c.id) FROM conversations c WHERE c.user_id = m.user_id AND c.role_id = m.role_id
+) WHERE m.conversation_id IS NULL OR m.conversation_id = 0;
 -- 创建role表
 CREATE TABLE IF NOT EXISTS roles (
     id     INT UNSIGNED NOT NULL AUTO_INCREMENT,
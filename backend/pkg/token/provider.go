@@ -0,0 +1,5 @@
+package token
+
+import "github.com/google/wire"
+
+var ProviderSet = wire.NewSet(NewKeySet)
@@ -0,0 +1,89 @@
+package token
+
+import (
+	"crypto/rand"
+	"demo/config"
+	"demo/pkg/log"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// KeySet 签发和验证 access token。header 中的 kid 决定用哪个密钥验证，轮换时先加入新密钥并设为当前，
+// 等旧 token 全部过期后再删除旧密钥
+type KeySet struct {
+	keys      map[string][]byte
+	current   string
+	accessTTL time.Duration
+}
+
+func NewKeySet(l *log.Logger, c *config.Config) *KeySet {
+	k := &KeySet{
+		keys:      map[string][]byte{},
+		current:   c.Jwt.CurrentKid,
+		accessTTL: time.Duration(c.Jwt.AccessTTL) * time.Second,
+	}
+	for kid, secret := range c.Jwt.Keys {
+		k.keys[kid] = []byte(secret)
+	}
+	if _, ok := k.keys[k.current]; !ok {
+		// 没有配置密钥时随机生成一个，重启后所有 token 失效
+		l.WithModule("KeySet").Warn("JWT_KEYS not configured or JWT_KID unknown, using a random signing key")
+		secret := make([]byte, 32)
+		_, _ = rand.Read(secret)
+		k.current = "random-" + uuid.NewString()[:8]
+		k.keys[k.current] = secret
+	}
+	return k
+}
+
+// AccessToken 为用户签发 access token，返回 token 和过期时间。role 和 perms 写入 claims，
+// 修改账号角色后需要刷新 token 才会生效
+func (k *KeySet) AccessToken(userID, role string, perms []string) (string, time.Time, error) {
+	now := time.Now()
+	exp := now.Add(k.accessTTL)
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"role":    role,
+		"perms":   perms,
+		"iat":     now.Unix(),
+		"exp":     exp.Unix(),
+		"jti":     uuid.NewString(),
+	})
+	t.Header["kid"] = k.current
+	s, err := t.SignedString(k.keys[k.current])
+	return s, exp, err
+}
+
+// Keyfunc 按 kid 选择验证密钥，供 jwt.Parse 使用
+func (k *KeySet) Keyfunc(t *jwt.Token) (any, error) {
+	if t.Method != jwt.SigningMethodHS256 {
+		return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+	}
+	kid, _ := t.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown kid")
+	}
+	return key, nil
+}
+
+// Parse 验证 access token 并返回其中的 claims
+func (k *KeySet) Parse(s string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(s, claims, k.Keyfunc); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// NewRefreshToken 生成随机的 refresh token，服务端只保存其哈希
+func NewRefreshToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,31 @@
+package token
+
+import (
+	"demo/config"
+	"demo/pkg/log"
+	"testing"
+)
+
+func TestKeyRotation(t *testing.T) {
+	c := &config.Config{Jwt: config.JwtConfig{Keys: map[string]string{"old": "s1"}, CurrentKid: "old", AccessTTL: 60}}
+	old := NewKeySet(log.NewLogger(c), c)
+	tok, _, err := old.AccessToken("u1", "user", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换：新密钥成为当前，旧密钥仍可验证
+	c.Jwt.Keys["new"] = "s2"
+	c.Jwt.CurrentKid = "new"
+	rotated := NewKeySet(log.NewLogger(c), c)
+	claims, err := rotated.Parse(tok)
+	if err != nil || claims["user_id"] != "u1" {
+		t.Fatalf("old token after rotation: %v, %v", claims, err)
+	}
+
+	// 删除旧密钥后旧 token 失效
+	delete(c.Jwt.Keys, "old")
+	if _, err := NewKeySet(log.NewLogger(c), c).Parse(tok); err == nil {
+		t.Error("token signed with a removed key should be rejected")
+	}
+}
@@ -6,6 +6,12 @@ import (
 	"demo/domain"
 	"demo/pkg/log"
 	"demo/pkg/store"
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
 )
 
 type ConversationMessageRepo struct {
@@ -26,12 +32,219 @@ func (c *ConversationMessageRepo) CreateMessage(ctx context.Context, m domain.Co
 	return c.db.DB.WithContext(ctx).Create(&m).Error
 }
 
-func (c ConversationMessageRepo) GetMessagesByUserIDAndRoleID(ctx context.Context, userID string, roleID int) ([]domain.ConversationMessage, error) {
+// GetMessagesByConversationID 按时间顺序列出对话线程中的全部消息（包括工具调用过程）
+func (c *ConversationMessageRepo) GetMessagesByConversationID(ctx context.Context, conversationID int) ([]domain.ConversationMessage, error) {
 	var messages []domain.ConversationMessage
-	err := c.db.DB.WithContext(ctx).Where("user_id = ? AND role_id = ?", userID, roleID).Order("id ASC").Find(&messages).Error
+	err := c.db.DB.WithContext(ctx).Where("conversation_id = ?", conversationID).Order("id ASC").Find(&messages).Error
 	if err != nil {
 		c.log.Error("err ", log.Error(err))
 		return nil, err
 	}
 	return messages, nil
 }
+
+// CountUserTurns 用户一共问过多少次，即全部对话中用户消息的条数
+func (c *ConversationMessageRepo) CountUserTurns(ctx context.Context, userID string) (int64, error) {
+	var n int64
+	err := c.db.DB.WithContext(ctx).Model(&domain.ConversationMessage{}).
+		Where("user_id = ? AND role = ?", userID, schema.User).Count(&n).Error
+	return n, err
+}
+
+func (c *ConversationMessageRepo) CreateConversation(ctx context.Context, conv *domain.Conversation) error {
+	if err := c.db.DB.WithContext(ctx).Create(conv).Error; err != nil {
+		return fmt.Errorf("failed to create conversation: %w", err)
+	}
+	return nil
+}
+
+// GetConversation 只能取到用户自己的对话
+func (c *ConversationMessageRepo) GetConversation(ctx context.Context, userID string, id int) (domain.Conversation, error) {
+	var conv domain.Conversation
+	if err := c.db.DB.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&conv).Error; err != nil {
+		return conv, fmt.Errorf("conversation %d not found: %w", id, err)
+	}
+	return conv, nil
+}
+
+// LatestConversation 用户与角色最近活跃的未归档对话
+func (c *ConversationMessageRepo) LatestConversation(ctx context.Context, userID string, roleID int) (domain.Conversation, error) {
+	var conv domain.Conversation
+	err := c.db.DB.WithContext(ctx).
+		Where("user_id = ? AND role_id = ? AND archived = ?", userID, roleID, false).
+		Order("updated_at DESC").
+		First(&conv).Error
+	return conv, err
+}
+
+func (c *ConversationMessageRepo) UpdateConversation(ctx context.Context, id int, updates map[string]any) error {
+	if err := c.db.DB.WithContext(ctx).Model(&domain.Conversation{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update conversation: %w", err)
+	}
+	return nil
+}
+
+// TouchConversation 更新对话的活跃时间，还没有标题时设置为 title
+func (c *ConversationMessageRepo) TouchConversation(ctx context.Context, id int, title string) error {
+	updates := map[string]any{"updated_at": time.Now()}
+	if title != "" {
+		updates["title"] = gorm.Expr("IF(title = '', ?, title)", title)
+	}
+	return c.UpdateConversation(ctx, id, updates)
+}
+
+// DeleteConversation 删除对话线程及其全部消息
+func (c *ConversationMessageRepo) DeleteConversation(ctx context.Context, id int) error {
+	return c.db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("conversation_id = ?", id).Delete(&domain.ConversationMessage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Conversation{}, id).Error
+	})
+}
+
+// visibleMessages 用户可见的消息：有内容的用户提问和模型回答，不包括工具调用过程
+func visibleMessages(db *gorm.DB) *gorm.DB {
+	return db.Where("role IN ? AND content <> ''", []schema.RoleType{schema.User, schema.Assistant})
+}
+
+type conversationStat struct {
+	ConversationID int
+	Count          int
+	LastID         int
+}
+
+// ListConversations 按最后活跃时间倒序列出用户的对话，附带最后一条消息
+func (c *ConversationMessageRepo) ListConversations(ctx context.Context, userID string, q domain.ConversationQuery) ([]domain.ConversationSummary, error) {
+	var convs []domain.Conversation
+	db := c.db.DB.WithContext(ctx).Where("user_id = ? AND archived = ?", userID, q.Archived)
+	if q.RoleID > 0 {
+		db = db.Where("role_id = ?", q.RoleID)
+	}
+	if err := db.Order("updated_at DESC").Find(&convs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	if len(convs) == 0 {
+		return []domain.ConversationSummary{}, nil
+	}
+
+	var stats []conversationStat
+	err := c.db.DB.WithContext(ctx).Model(&domain.ConversationMessage{}).
+		Scopes(visibleMessages).
+		Select("conversation_id, COUNT(*) AS count, MAX(id) AS last_id").
+		Where("conversation_id IN ?", lo.Map(convs, func(conv domain.Conversation, _ int) int { return conv.ID })).
+		Group("conversation_id").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count messages: %w", err)
+	}
+	statByID := lo.KeyBy(stats, func(s conversationStat) int { return s.ConversationID })
+
+	var last []domain.ConversationMessage
+	if len(stats) > 0 {
+		if err := c.db.DB.WithContext(ctx).Where("id IN ?", lo.Map(stats, func(s conversationStat, _ int) int { return s.LastID })).Find(&last).Error; err != nil {
+			return nil, fmt.Errorf("failed to get last messages: %w", err)
+		}
+	}
+	lastByConv := lo.KeyBy(last, func(m domain.ConversationMessage) int { return m.ConversationID })
+
+	var roles []domain.Role
+	if err := c.db.DB.WithContext(ctx).Where("id IN ?", lo.Uniq(lo.Map(convs, func(conv domain.Conversation, _ int) int { return conv.RoleID }))).Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+	roleByID := lo.KeyBy(roles, func(r domain.Role) int { return r.ID })
+
+	list := make([]domain.ConversationSummary, 0, len(convs))
+	for _, conv := range convs {
+		role := roleByID[conv.RoleID]
+		m := lastByConv[conv.ID]
+		list = append(list, domain.ConversationSummary{
+			Conversation: conv,
+			RoleName:     role.Name,
+			RoleImageUrl: role.ImageUrl,
+			LastMessage:  m.Content,
+			LastSender:   string(m.Role),
+			MessageCount: statByID[conv.ID].Count,
+		})
+	}
+	return list, nil
+}
+
+// ListMessages 按 id 倒序分页列出用户可见的消息，beforeID 为 0 时从最新开始
+func (c *ConversationMessageRepo) ListMessages(ctx context.Context, conversationID, beforeID, limit int) ([]domain.ConversationMessage, error) {
+	var messages []domain.ConversationMessage
+	db := c.db.DB.WithContext(ctx).Scopes(visibleMessages).Where("conversation_id = ?", conversationID)
+	if beforeID > 0 {
+		db = db.Where("id < ?", beforeID)
+	}
+	if err := db.Order("id DESC").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	return messages, nil
+}
+
+// ListVisibleMessages 按时间顺序列出对话中用户可见的全部消息
+func (c *ConversationMessageRepo) ListVisibleMessages(ctx context.Context, conversationID int) ([]domain.ConversationMessage, error) {
+	var messages []domain.ConversationMessage
+	err := c.db.DB.WithContext(ctx).Scopes(visibleMessages).
+		Where("conversation_id = ?", conversationID).
+		Order("id ASC").
+		Find(&messages).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	return messages, nil
+}
+
+// DeleteMessage 删除一条消息；删除模型回答时一并删除这一轮的工具调用过程，避免历史中出现不成对的工具消息。
+// 返回被删除消息的音频 key
+func (c *ConversationMessageRepo) DeleteMessage(ctx context.Context, conversationID, id int) ([]string, error) {
+	var audioKeys []string
+	err := c.db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var m domain.ConversationMessage
+		if err := tx.Where("id = ? AND conversation_id = ?", id, conversationID).First(&m).Error; err != nil {
+			return fmt.Errorf("message %d not found: %w", id, err)
+		}
+		ids := []int{m.ID}
+		if m.AudioKey != "" {
+			audioKeys = append(audioKeys, m.AudioKey)
+		}
+		if m.Role == schema.Assistant {
+			var prev []domain.ConversationMessage
+			err := tx.Where("conversation_id = ? AND id < ?", conversationID, m.ID).
+				Order("id DESC").Limit(20).Find(&prev).Error
+			if err != nil {
+				return err
+			}
+			for _, p := range prev {
+				if p.Role != schema.Tool && len(p.ToolCalls) == 0 {
+					break
+				}
+				ids = append(ids, p.ID)
+			}
+		}
+		return tx.Where("id IN ?", ids).Delete(&domain.ConversationMessage{}).Error
+	})
+	return audioKeys, err
+}
+
+// ListAudioKeys 对话中所有消息的音频 key
+func (c *ConversationMessageRepo) ListAudioKeys(ctx context.Context, conversationID int) ([]string, error) {
+	var keys []string
+	err := c.db.DB.WithContext(ctx).Model(&domain.ConversationMessage{}).
+		Where("conversation_id = ? AND audio_key <> ''", conversationID).
+		Pluck("audio_key", &keys).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audio keys: %w", err)
+	}
+	return keys, nil
+}
+
+// ClearConversation 删除对话中的全部消息，保留对话本身，返回删除的条数
+func (c *ConversationMessageRepo) ClearConversation(ctx context.Context, conversationID int) (int64, error) {
+	res := c.db.DB.WithContext(ctx).Where("conversation_id = ?", conversationID).Delete(&domain.ConversationMessage{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("failed to clear conversation: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"context"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/pkg/store"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CollectionRepo 用户与角色之间的点赞、收藏关系
+type CollectionRepo struct {
+	log    *log.Logger
+	config *config.Config
+	db     *store.MySQL
+}
+
+func NewCollectionRepo(log *log.Logger, config *config.Config, db *store.MySQL) *CollectionRepo {
+	return &CollectionRepo{
+		log:    log.WithModule("CollectionRepo"),
+		config: config,
+		db:     db,
+	}
+}
+
+// Like 点赞，重复点赞不改变计数；返回最新的点赞数
+func (r *CollectionRepo) Like(ctx context.Context, userID string, roleID int) (int, error) {
+	var likes int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.UserRoleLike{UserID: userID, RoleID: roleID})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 1 {
+			if err := tx.Model(&domain.Role{}).Where("id = ?", roleID).UpdateColumn("likes", gorm.Expr("likes + 1")).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&domain.Role{}).Where("id = ?", roleID).Pluck("likes", &likes).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to like role: %w", err)
+	}
+	return likes, nil
+}
+
+// Unlike 取消点赞，没有点赞过时不改变计数；返回最新的点赞数
+func (r *CollectionRepo) Unlike(ctx context.Context, userID string, roleID int) (int, error) {
+	var likes int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&domain.UserRoleLike{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 1 {
+			if err := tx.Model(&domain.Role{}).Where("id = ? AND likes > 0", roleID).UpdateColumn("likes", gorm.Expr("likes - 1")).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&domain.Role{}).Where("id = ?", roleID).Pluck("likes", &likes).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to unlike role: %w", err)
+	}
+	return likes, nil
+}
+
+func (r *CollectionRepo) AddFavorite(ctx context.Context, userID string, roleID int) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&domain.UserRoleFavorite{UserID: userID, RoleID: roleID}).Error
+}
+
+func (r *CollectionRepo) RemoveFavorite(ctx context.Context, userID string, roleID int) error {
+	return r.db.WithContext(ctx).Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&domain.UserRoleFavorite{}).Error
+}
+
+// ListFavorites 按收藏时间倒序列出用户收藏且仍可见的角色
+func (r *CollectionRepo) ListFavorites(ctx context.Context, userID string) ([]domain.Role, error) {
+	var roles []domain.Role
+	err := r.db.WithContext(ctx).
+		Joins("JOIN user_role_favorites f ON f.role_id = roles.id").
+		Where("f.user_id = ?", userID).
+		Scopes(visibleRoles(userID)).
+		Order("f.created_at DESC").
+		Find(&roles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list favorites: %w", err)
+	}
+	return roles, nil
+}
+
+// ListRecentRoles 按最后一次对话时间倒序列出用户聊过的角色
+func (r *CollectionRepo) ListRecentRoles(ctx context.Context, userID string, limit int) ([]domain.Role, error) {
+	var roles []domain.Role
+	recent := r.db.WithContext(ctx).Model(&domain.ConversationMessage{}).
+		Select("role_id, MAX(time) AS last_time").
+		Where("user_id = ?", userID).
+		Group("role_id")
+	err := r.db.WithContext(ctx).
+		Joins("JOIN (?) m ON m.role_id = roles.id", recent).
+		Scopes(visibleRoles(userID)).
+		Order("m.last_time DESC").
+		Limit(limit).
+		Find(&roles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recent roles: %w", err)
+	}
+	return roles, nil
+}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"context"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/pkg/store"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type KnowledgeRepo struct {
+	log    *log.Logger
+	config *config.Config
+	db     *store.MySQL
+}
+
+func NewKnowledgeRepo(log *log.Logger, config *config.Config, db *store.MySQL) *KnowledgeRepo {
+	return &KnowledgeRepo{
+		log:    log.WithModule("KnowledgeRepo"),
+		config: config,
+		db:     db,
+	}
+}
+
+// CreateDocument 在同一事务中保存资料及其段落
+func (r *KnowledgeRepo) CreateDocument(ctx context.Context, doc *domain.KnowledgeDocument, chunks []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		doc.Chunks = len(chunks)
+		if err := tx.Create(doc).Error; err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		rows := make([]domain.KnowledgeChunk, 0, len(chunks))
+		for i, c := range chunks {
+			rows = append(rows, domain.KnowledgeChunk{DocumentID: doc.ID, RoleID: doc.RoleID, Seq: i, Content: c})
+		}
+		return tx.CreateInBatches(rows, 100).Error
+	})
+}
+
+func (r *KnowledgeRepo) ListDocuments(ctx context.Context, roleID int) ([]domain.KnowledgeDocument, error) {
+	var docs []domain.KnowledgeDocument
+	if err := r.db.WithContext(ctx).Where("role_id = ?", roleID).Order("id ASC").Find(&docs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	return docs, nil
+}
+
+func (r *KnowledgeRepo) GetDocument(ctx context.Context, roleID, id int) (domain.KnowledgeDocument, error) {
+	var doc domain.KnowledgeDocument
+	if err := r.db.WithContext(ctx).Where("role_id = ?", roleID).First(&doc, id).Error; err != nil {
+		return domain.KnowledgeDocument{}, fmt.Errorf("failed to get document: %w", err)
+	}
+	return doc, nil
+}
+
+func (r *KnowledgeRepo) DeleteDocument(ctx context.Context, roleID, id int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ? AND role_id = ?", id, roleID).Delete(&domain.KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("role_id = ?", roleID).Delete(&domain.KnowledgeDocument{}, id).Error
+	})
+}
+
+func (r *KnowledgeRepo) ListChunks(ctx context.Context, roleID int) ([]domain.KnowledgeChunk, error) {
+	var chunks []domain.KnowledgeChunk
+	if err := r.db.WithContext(ctx).Where("role_id = ?", roleID).Order("document_id ASC, seq ASC").Find(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,41 @@
+package repo
+
+import (
+	"context"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/pkg/store"
+	"fmt"
+)
+
+type ModerationRepo struct {
+	log    *log.Logger
+	config *config.Config
+	db     *store.MySQL
+}
+
+func NewModerationRepo(log *log.Logger, config *config.Config, db *store.MySQL) *ModerationRepo {
+	return &ModerationRepo{
+		log:    log.WithModule("ModerationRepo"),
+		config: config,
+		db:     db,
+	}
+}
+
+func (r *ModerationRepo) CreateEvent(ctx context.Context, e domain.ModerationEvent) error {
+	return r.db.WithContext(ctx).Create(&e).Error
+}
+
+// ListEvents 按时间倒序分页，beforeID 为 0 时从最新开始
+func (r *ModerationRepo) ListEvents(ctx context.Context, beforeID, limit int) ([]domain.ModerationEvent, error) {
+	var events []domain.ModerationEvent
+	q := r.db.WithContext(ctx).Order("id DESC").Limit(limit)
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	if err := q.Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to list moderation events: %w", err)
+	}
+	return events, nil
+}
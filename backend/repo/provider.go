@@ -11,4 +11,9 @@ var ProviderSet = wire.NewSet(
 	NewRoleRepo,
 	NewUserRepo,
 	NewConversationRepo,
+	NewKnowledgeRepo,
+	NewModerationRepo,
+	NewCollectionRepo,
+	NewTokenRepo,
+	NewUsageRepo,
 )
@@ -6,7 +6,14 @@ import (
 	"demo/domain"
 	"demo/pkg/log"
 	"demo/pkg/store"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RoleRepo struct {
@@ -23,11 +30,18 @@ func NewRoleRepo(log *log.Logger, config *config.Config, db *store.MySQL) *RoleR
 	}
 }
 
-func (r *RoleRepo) CreateRole(ctx context.Context, role domain.Role) error {
+// CreateRole 创建角色并保存第一个版本
+func (r *RoleRepo) CreateRole(ctx context.Context, role *domain.Role, version domain.RoleVersion) error {
 	if _, err := r.GetRoleByName(ctx, role.Name); err == nil {
 		return fmt.Errorf("role already exists")
 	}
-	return r.db.WithContext(ctx).Create(&role).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(role).Error; err != nil {
+			return err
+		}
+		version.RoleID = role.ID
+		return tx.Create(&version).Error
+	})
 }
 
 func (r *RoleRepo) GetRoleByName(ctx context.Context, name string) (domain.Role, error) {
@@ -44,18 +58,291 @@ func (r *RoleRepo) GetroleById(ctx context.Context, id int) (domain.Role, error)
 	}
 	return role, nil
 }
-func (r *RoleRepo) UpdateRole(ctx context.Context, role domain.Role) error {
-	return r.db.WithContext(ctx).Save(&role).Error
+
+// UpdateRole 保存角色，同时按顺序保存传入的版本
+func (r *RoleRepo) UpdateRole(ctx context.Context, role domain.Role, versions ...domain.RoleVersion) error {
+	if other, err := r.GetRoleByName(ctx, role.Name); err == nil && other.ID != role.ID {
+		return fmt.Errorf("role already exists")
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 浏览量和点赞数由计数接口维护，编辑时不覆盖
+		if err := tx.Omit("views", "likes", "created_at").Save(&role).Error; err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			return nil
+		}
+		return tx.Create(&versions).Error
+	})
+}
+
+func (r *RoleRepo) UpdateStatus(ctx context.Context, id int, status string) error {
+	res := r.db.WithContext(ctx).Model(&domain.Role{}).Where("id = ?", id).Update("status", status)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("role %d not found", id)
+	}
+	return nil
+}
+
+// IncrViews 原子地把浏览量加一
+func (r *RoleRepo) IncrViews(ctx context.Context, id int) error {
+	return r.db.WithContext(ctx).Model(&domain.Role{}).Where("id = ?", id).
+		UpdateColumn("views", gorm.Expr("views + 1")).Error
+}
+
+// DeleteRole 删除角色及其版本、知识库和所有用户在该角色上的对话、点赞、收藏，返回需要从 OSS 删除的文件 key
+func (r *RoleRepo) DeleteRole(ctx context.Context, id int) ([]string, error) {
+	var fileKeys []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		fileKeys, err = deleteRoles(tx, []int{id})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete role: %w", err)
+	}
+	return fileKeys, nil
 }
 
-func (r *RoleRepo) DeleteRole(ctx context.Context, id int) error {
-	return r.db.WithContext(ctx).Delete(&domain.Role{}, id).Error
+// deleteRoles 在事务中删除角色及其关联数据，返回消息音频和知识库文档的文件 key
+func deleteRoles(tx *gorm.DB, ids []int) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var fileKeys, docKeys []string
+	if err := tx.Model(&domain.ConversationMessage{}).
+		Where("role_id IN ? AND audio_key <> ''", ids).
+		Pluck("audio_key", &fileKeys).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&domain.KnowledgeDocument{}).
+		Where("role_id IN ? AND file_key <> ''", ids).
+		Pluck("file_key", &docKeys).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range []any{
+		&domain.RoleVersion{},
+		&domain.KnowledgeChunk{},
+		&domain.KnowledgeDocument{},
+		&domain.ConversationMessage{},
+		&domain.Conversation{},
+		&domain.UserRoleLike{},
+		&domain.UserRoleFavorite{},
+	} {
+		if err := tx.Where("role_id IN ?", ids).Delete(m).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Where("id IN ?", ids).Delete(&domain.Role{}).Error; err != nil {
+		return nil, err
+	}
+	return append(fileKeys, docKeys...), nil
+}
+
+// publicRoles 所有人可见的角色：已发布、公开且审核通过
+func publicRoles(db *gorm.DB) *gorm.DB {
+	return db.Where("roles.status = ? AND roles.visibility = ? AND roles.review_status = ?",
+		domain.RolePublished, domain.RolePublic, domain.ReviewApproved)
 }
 
+// visibleRoles 对 userID 可见的角色：公开的角色加上自己创建的已发布角色
+func visibleRoles(userID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("roles.status = ? AND ((roles.visibility = ? AND roles.review_status = ?) OR roles.owner_id = ?)",
+			domain.RolePublished, domain.RolePublic, domain.ReviewApproved, userID)
+	}
+}
+
+// ListRoles 列出公开的角色
 func (r *RoleRepo) ListRoles(ctx context.Context) ([]domain.Role, error) {
 	var roles []domain.Role
-	if err := r.db.WithContext(ctx).Find(&roles).Error; err != nil {
+	if err := r.db.WithContext(ctx).Scopes(publicRoles).Find(&roles).Error; err != nil {
 		return nil, fmt.Errorf("failed to list roles: %w", err)
 	}
 	return roles, nil
 }
+
+// ListAllRoles 列出包括草稿在内的所有角色
+func (r *RoleRepo) ListAllRoles(ctx context.Context) ([]domain.Role, error) {
+	var roles []domain.Role
+	if err := r.db.WithContext(ctx).Order("id ASC").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *RoleRepo) CountByOwner(ctx context.Context, ownerID string) (int64, error) {
+	var n int64
+	if err := r.db.WithContext(ctx).Model(&domain.Role{}).Where("owner_id = ?", ownerID).Count(&n).Error; err != nil {
+		return 0, fmt.Errorf("failed to count roles: %w", err)
+	}
+	return n, nil
+}
+
+func (r *RoleRepo) ListByOwner(ctx context.Context, ownerID string) ([]domain.Role, error) {
+	var roles []domain.Role
+	if err := r.db.WithContext(ctx).Where("owner_id = ?", ownerID).Order("id DESC").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	return roles, nil
+}
+
+// ListByReviewStatus 按提交时间先后列出指定审核状态的公开角色
+func (r *RoleRepo) ListByReviewStatus(ctx context.Context, status string) ([]domain.Role, error) {
+	var roles []domain.Role
+	err := r.db.WithContext(ctx).Where("visibility = ? AND review_status = ?", domain.RolePublic, status).
+		Order("updated_at ASC").Find(&roles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *RoleRepo) UpdateReview(ctx context.Context, id int, status, note string) error {
+	res := r.db.WithContext(ctx).Model(&domain.Role{}).Where("id = ?", id).
+		Updates(map[string]any{"review_status": status, "review_note": note})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("role %d not found", id)
+	}
+	return nil
+}
+
+func (r *RoleRepo) ListVersions(ctx context.Context, roleID int) ([]domain.RoleVersion, error) {
+	var versions []domain.RoleVersion
+	if err := r.db.WithContext(ctx).Where("role_id = ?", roleID).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list role versions: %w", err)
+	}
+	return versions, nil
+}
+
+func (r *RoleRepo) CountVersions(ctx context.Context, roleID int) (int64, error) {
+	var n int64
+	if err := r.db.WithContext(ctx).Model(&domain.RoleVersion{}).Where("role_id = ?", roleID).Count(&n).Error; err != nil {
+		return 0, fmt.Errorf("failed to count role versions: %w", err)
+	}
+	return n, nil
+}
+
+func (r *RoleRepo) GetVersion(ctx context.Context, roleID, version int) (domain.RoleVersion, error) {
+	var v domain.RoleVersion
+	if err := r.db.WithContext(ctx).Where("role_id = ? AND version = ?", roleID, version).First(&v).Error; err != nil {
+		return domain.RoleVersion{}, fmt.Errorf("failed to get role version: %w", err)
+	}
+	return v, nil
+}
+
+// roleCursor 列表游标：按浏览量、点赞量排序时记录上一页最后一条的值和 id，按相关度排序时记录偏移
+type roleCursor struct {
+	Value  int `json:"v,omitempty"`
+	ID     int `json:"id,omitempty"`
+	Offset int `json:"o,omitempty"`
+}
+
+func encodeRoleCursor(c roleCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeRoleCursor(s string) (roleCursor, error) {
+	var c roleCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("invalid cursor: %w", err)
+	}
+	return c, nil
+}
+
+const roleMatch = "MATCH(name, tags, description) AGAINST (?)"
+
+// SearchRoles 搜索已发布的角色，返回一页结果和下一页游标。
+// 搜索词不少于两个字时走 ngram 全文索引，否则按名称、简介模糊匹配。
+// 相关度是浮点数，无法可靠地作为游标比较，所以按相关度排序时用偏移分页，翻页期间角色有增改可能出现重复或遗漏
+
+func (r *RoleRepo) SearchRoles(ctx context.Context, q domain.RoleQuery) ([]domain.Role, string, error) {
+	if q.Limit <= 0 || q.Limit > 50 {
+		q.Limit = 20
+	}
+	var cursor roleCursor
+	if q.Cursor != "" {
+		c, err := decodeRoleCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		cursor = c
+	}
+
+	db := r.db.WithContext(ctx).Model(&domain.Role{}).Scopes(publicRoles)
+	keyword := strings.TrimSpace(q.Q)
+	fulltext := utf8.RuneCountInString(keyword) >= 2
+	switch {
+	case fulltext:
+		db = db.Where(roleMatch, keyword)
+	case keyword != "":
+		like := "%" + likeEscaper.Replace(keyword) + "%"
+		db = db.Where("name LIKE ? OR description LIKE ?", like, like)
+	}
+	if q.Category != "" {
+		db = db.Where("category = ?", q.Category)
+	}
+	if q.Tag != "" {
+		db = db.Where("JSON_CONTAINS(tags, JSON_QUOTE(?))", q.Tag)
+	}
+
+	sort := q.Sort
+	if sort == "" || (sort == domain.RoleSortRelevance && !fulltext) {
+		sort = domain.RoleSortNewest
+		if fulltext {
+			sort = domain.RoleSortRelevance
+		}
+	}
+	column := ""
+	switch sort {
+	case domain.RoleSortRelevance:
+		db = db.Order(clause.Expr{SQL: roleMatch + " DESC", Vars: []any{keyword}}).Order("id DESC").Offset(cursor.Offset)
+	case domain.RoleSortNewest:
+		if cursor.ID > 0 {
+			db = db.Where("id < ?", cursor.ID)
+		}
+		db = db.Order("id DESC")
+	case domain.RoleSortViews, domain.RoleSortLikes:
+		column = sort
+		if cursor.ID > 0 {
+			db = db.Where(fmt.Sprintf("%s < ? OR (%s = ? AND id < ?)", column, column), cursor.Value, cursor.Value, cursor.ID)
+		}
+		db = db.Order(column + " DESC").Order("id DESC")
+	default:
+		return nil, "", fmt.Errorf("unknown sort: %s", q.Sort)
+	}
+
+	var roles []domain.Role
+	if err := db.Limit(q.Limit + 1).Find(&roles).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to search roles: %w", err)
+	}
+	if len(roles) <= q.Limit {
+		return roles, "", nil
+	}
+	roles = roles[:q.Limit]
+	last := roles[len(roles)-1]
+	next := roleCursor{ID: last.ID}
+	switch column {
+	case domain.RoleSortViews:
+		next.Value = last.Views
+	case domain.RoleSortLikes:
+		next.Value = last.Likes
+	}
+	if sort == domain.RoleSortRelevance {
+		next = roleCursor{Offset: cursor.Offset + q.Limit}
+	}
+	return roles, encodeRoleCursor(next), nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
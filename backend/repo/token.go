@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/pkg/store"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrRefreshTokenReused 已轮换过的 refresh token 被再次使用
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+type TokenRepo struct {
+	log    *log.Logger
+	config *config.Config
+	db     *store.MySQL
+}
+
+func NewTokenRepo(log *log.Logger, config *config.Config, db *store.MySQL) *TokenRepo {
+	return &TokenRepo{
+		log:    log.WithModule("TokenRepo"),
+		config: config,
+		db:     db,
+	}
+}
+
+func (r *TokenRepo) CreateRefreshToken(ctx context.Context, t domain.RefreshToken) error {
+	if err := r.db.WithContext(ctx).Create(&t).Error; err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken 作废旧 token 并保存新 token。旧 token 已作废时作废整个 Family 并返回 ErrRefreshTokenReused
+func (r *TokenRepo) RotateRefreshToken(ctx context.Context, oldHash string, next domain.RefreshToken) (domain.RefreshToken, error) {
+	var old domain.RefreshToken
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("hash = ?", oldHash).First(&old).Error; err != nil {
+			return fmt.Errorf("refresh token not found: %w", err)
+		}
+		if old.RevokedAt != nil {
+			return ErrRefreshTokenReused
+		}
+		if time.Now().After(old.ExpiresAt) {
+			return errors.New("refresh token expired")
+		}
+		now := time.Now()
+		res := tx.Model(&domain.RefreshToken{}).
+			Where("hash = ? AND revoked_at IS NULL", oldHash).
+			Updates(map[string]any{"revoked_at": now, "replaced_by": next.Hash})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// 并发刷新，另一个请求先完成了轮换
+			return ErrRefreshTokenReused
+		}
+		next.UserID = old.UserID
+		next.FamilyID = old.FamilyID
+		return tx.Create(&next).Error
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if e := r.RevokeFamily(ctx, old.FamilyID); e != nil {
+			r.log.Error("revoke token family failed", log.Error(e))
+		}
+	}
+	return next, err
+}
+
+// RevokeByHash 作废用户的这个 token 所在的 Family（登出当前设备）
+func (r *TokenRepo) RevokeByHash(ctx context.Context, userID, hash string) error {
+	var t domain.RefreshToken
+	if err := r.db.WithContext(ctx).Where("hash = ? AND user_id = ?", hash, userID).First(&t).Error; err != nil {
+		return fmt.Errorf("refresh token not found: %w", err)
+	}
+	return r.RevokeFamily(ctx, t.FamilyID)
+}
+
+func (r *TokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.db.WithContext(ctx).Model(&domain.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUser 作废用户的全部 refresh token（登出所有设备）
+func (r *TokenRepo) RevokeUser(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Model(&domain.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
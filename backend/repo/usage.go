@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"context"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/pkg/store"
+	"fmt"
+	"time"
+)
+
+type UsageRepo struct {
+	log    *log.Logger
+	config *config.Config
+	db     *store.MySQL
+}
+
+func NewUsageRepo(log *log.Logger, config *config.Config, db *store.MySQL) *UsageRepo {
+	return &UsageRepo{
+		log:    log.WithModule("UsageRepo"),
+		config: config,
+		db:     db,
+	}
+}
+
+func (r *UsageRepo) CreateUsage(ctx context.Context, rec domain.UsageRecord) error {
+	if err := r.db.WithContext(ctx).Create(&rec).Error; err != nil {
+		return fmt.Errorf("failed to create usage record: %w", err)
+	}
+	return nil
+}
+
+// SumUsage 合计用户从 since 开始的用量
+func (r *UsageRepo) SumUsage(ctx context.Context, userID string, since time.Time) (domain.UsageSummary, error) {
+	var s domain.UsageSummary
+	err := r.db.WithContext(ctx).Model(&domain.UsageRecord{}).
+		Select("COUNT(*) AS turns, COALESCE(SUM(asr_ms), 0) AS asr_ms, "+
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens, "+
+			"COALESCE(SUM(tts_chars), 0) AS tts_chars, COALESCE(SUM(tts_ms), 0) AS tts_ms").
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Scan(&s).Error
+	if err != nil {
+		return s, fmt.Errorf("failed to sum usage: %w", err)
+	}
+	return s, nil
+}
@@ -6,11 +6,13 @@ import (
 	"demo/domain"
 	"demo/pkg/log"
 	"demo/pkg/store"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type UserRepo struct {
@@ -27,16 +29,93 @@ func NewUserRepo(log *log.Logger, config *config.Config, db *store.MySQL) *UserR
 	}
 }
 
-func (r *UserRepo) CreateUser(ctx context.Context, user domain.User) error {
-	if _, err := r.GetUserByName(ctx, user.Name); err == nil {
-		return fmt.Errorf("user already exists")
+// CreateUser 生成 id 和注册时间后保存，用户名重复由唯一索引保证
+func (r *UserRepo) CreateUser(ctx context.Context, user domain.User) (domain.User, error) {
+	user, err := newUser(user)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if err := createUser(r.db.WithContext(ctx), &user); err != nil {
+		return domain.User{}, err
 	}
+	return user, nil
+}
+
+func newUser(user domain.User) (domain.User, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+		return domain.User{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.ID = uuid.NewString()
+	user.CreateTime = time.Now().Unix()
+	if user.Role == "" {
+		user.Role = domain.AccountUser
 	}
 	user.Password = string(hashedPassword)
-	return r.db.WithContext(ctx).Create(&user).Error
+	return user, nil
+}
+
+func createUser(db *gorm.DB, user *domain.User) error {
+	if err := db.Create(user).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("user already exists")
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// ClaimGuest 用游客注册正式账号：创建新用户，把游客的对话、点赞收藏、自建角色、审核和用量记录转到新用户下，
+// 然后删除游客和它的登录凭证
+func (r *UserRepo) ClaimGuest(ctx context.Context, guestID string, user domain.User) (domain.User, error) {
+	user, err := newUser(user)
+	if err != nil {
+		return domain.User{}, err
+	}
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var guest domain.User
+		if err := tx.Where("id = ? AND role = ?", guestID, domain.AccountGuest).First(&guest).Error; err != nil {
+			return fmt.Errorf("guest not found: %w", err)
+		}
+		user.Nickname = guest.Nickname
+		user.Language = guest.Language
+		user.VoiceSpeed = guest.VoiceSpeed
+		if err := createUser(tx, &user); err != nil {
+			return err
+		}
+		for _, m := range []any{
+			&domain.ConversationMessage{},
+			&domain.Conversation{},
+			&domain.UserRoleLike{},
+			&domain.UserRoleFavorite{},
+			&domain.ModerationEvent{},
+			&domain.UsageRecord{},
+		} {
+			if err := tx.Model(m).Where("user_id = ?", guestID).Update("user_id", user.ID).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&domain.Role{}).Where("owner_id = ?", guestID).Update("owner_id", user.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", guestID).Delete(&domain.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", guestID).Delete(&domain.User{}).Error
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+	return user, nil
+}
+
+// ListExpiredGuests 返回 before 之前创建、还没有注册的游客 id
+func (r *UserRepo) ListExpiredGuests(ctx context.Context, before int64, limit int) ([]string, error) {
+	var ids []string
+	err := r.db.WithContext(ctx).Model(&domain.User{}).
+		Where("role = ? AND create_time < ?", domain.AccountGuest, before).
+		Order("create_time").Limit(limit).Pluck("id", &ids).Error
+	return ids, err
 }
 
 func (r *UserRepo) GetUserByName(ctx context.Context, name string) (domain.User, error) {
@@ -54,20 +133,93 @@ func (r *UserRepo) UpDataPassword(ctx context.Context, user domain.User, newPass
 	user.Password = string(hashedPassword)
 	return r.db.WithContext(ctx).Save(&user).Error
 }
-func (r *UserRepo) VertifyUserPasswordAndGenerateToken(ctx context.Context, user domain.User) (string, error) {
-	originPassword := user.Password
-	user, err := r.GetUserByName(ctx, user.Name)
+
+// VerifyPassword 校验用户名和密码，返回用户
+func (r *UserRepo) VerifyPassword(ctx context.Context, name, password string) (domain.User, error) {
+	user, err := r.GetUserByName(ctx, name)
 	if err != nil {
-		return "", fmt.Errorf("failed to get user by name: %w", err)
+		return domain.User{}, fmt.Errorf("failed to get user by name: %w", err)
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(originPassword)); err != nil {
-		return "", fmt.Errorf("password is not correct")
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return domain.User{}, fmt.Errorf("password is not correct")
+	}
+	return user, nil
+}
+
+func (r *UserRepo) GetUserByID(ctx context.Context, id string) (domain.User, error) {
+	var user domain.User
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&user).Error; err != nil {
+		return domain.User{}, fmt.Errorf("failed to get user: %w", err)
 	}
-	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+	return user, nil
+}
+
+func (r *UserRepo) UpdateProfile(ctx context.Context, id string, updates map[string]any) error {
+	if err := r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepo) UpdateRole(ctx context.Context, id, role string) error {
+	res := r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Update("role", role)
+	if res.Error != nil {
+		return fmt.Errorf("failed to update role: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		var n int64
+		if err := r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return gorm.ErrRecordNotFound
+		}
+	}
+	return nil
+}
+
+// DeleteUser 删除用户及其全部数据：对话、点赞收藏、自建角色、登录凭证、审核和用量记录。
+// 自建角色的版本、知识库，以及其他用户在这些角色上的对话和点赞收藏一并删除。返回需要从 OSS 删除的文件 key
+func (r *UserRepo) DeleteUser(ctx context.Context, id string) ([]string, error) {
+	var fileKeys []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var owned []int
+		if err := tx.Model(&domain.Role{}).Where("owner_id = ?", id).Pluck("id", &owned).Error; err != nil {
+			return err
+		}
+		// 点赞数随点赞记录一起回退
+		liked := tx.Model(&domain.UserRoleLike{}).Select("role_id").Where("user_id = ?", id)
+		if err := tx.Model(&domain.Role{}).Where("id IN (?)", liked).
+			UpdateColumn("likes", gorm.Expr("GREATEST(likes - 1, 0)")).Error; err != nil {
+			return err
+		}
+		roleKeys, err := deleteRoles(tx, owned)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.ConversationMessage{}).
+			Where("user_id = ? AND audio_key <> ''", id).
+			Pluck("audio_key", &fileKeys).Error; err != nil {
+			return err
+		}
+		fileKeys = append(fileKeys, roleKeys...)
+		for _, m := range []any{
+			&domain.ConversationMessage{},
+			&domain.Conversation{},
+			&domain.UserRoleLike{},
+			&domain.UserRoleFavorite{},
+			&domain.RefreshToken{},
+			&domain.ModerationEvent{},
+			&domain.UsageRecord{},
+		} {
+			if err := tx.Where("user_id = ?", id).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id = ?", id).Delete(&domain.User{}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete user: %w", err)
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte("secret"))
-	return tokenString, err
+	return fileKeys, nil
 }
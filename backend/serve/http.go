@@ -10,6 +10,8 @@ type HttpServer struct {
 
 func NewHttpServer() *HttpServer {
 	e := echo.New()
+	// 登录限流按 RealIP 计数，只信任直连地址，防止伪造 X-Forwarded-For 绕过
+	e.IPExtractor = echo.ExtractIPDirect()
 	return &HttpServer{
 		Echo: e,
 	}
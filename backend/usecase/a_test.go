@@ -64,7 +64,7 @@ func Test_B(t *testing.T) {
 		close(chunks)
 	}()
 
-	pcmStream, errCh := l.TtsStream(context.Background(), chunks, "qiniu_zh_female_tmjxxy", 1.0)
+	pcmStream, errCh := l.TtsStream(context.Background(), chunks, "qiniu_zh_female_tmjxxy")
 
 	for {
 		select {
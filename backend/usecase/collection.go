@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"context"
+	"demo/domain"
+	"demo/repo"
+	"fmt"
+
+	"github.com/samber/lo"
+)
+
+// CollectionUsecase 点赞、收藏和最近聊过的角色
+type CollectionUsecase struct {
+	collectionRepo *repo.CollectionRepo
+	roleRepo       *repo.RoleRepo
+}
+
+func NewCollectionUsecase(collectionRepo *repo.CollectionRepo, roleRepo *repo.RoleRepo) *CollectionUsecase {
+	return &CollectionUsecase{
+		collectionRepo: collectionRepo,
+		roleRepo:       roleRepo,
+	}
+}
+
+// recentRolesLimit 最近聊过的角色最多返回的个数
+const recentRolesLimit = 20
+
+func (u *CollectionUsecase) Like(ctx context.Context, userID string, roleID int) (domain.LikeResp, error) {
+	if err := u.checkRole(ctx, userID, roleID); err != nil {
+		return domain.LikeResp{}, err
+	}
+	likes, err := u.collectionRepo.Like(ctx, userID, roleID)
+	if err != nil {
+		return domain.LikeResp{}, err
+	}
+	return domain.LikeResp{Liked: true, Likes: likes}, nil
+}
+
+func (u *CollectionUsecase) Unlike(ctx context.Context, userID string, roleID int) (domain.LikeResp, error) {
+	likes, err := u.collectionRepo.Unlike(ctx, userID, roleID)
+	if err != nil {
+		return domain.LikeResp{}, err
+	}
+	return domain.LikeResp{Liked: false, Likes: likes}, nil
+}
+
+func (u *CollectionUsecase) AddFavorite(ctx context.Context, userID string, roleID int) error {
+	if err := u.checkRole(ctx, userID, roleID); err != nil {
+		return err
+	}
+	return u.collectionRepo.AddFavorite(ctx, userID, roleID)
+}
+
+func (u *CollectionUsecase) RemoveFavorite(ctx context.Context, userID string, roleID int) error {
+	return u.collectionRepo.RemoveFavorite(ctx, userID, roleID)
+}
+
+func (u *CollectionUsecase) ListFavorites(ctx context.Context, userID string) (domain.RoleList, error) {
+	roles, err := u.collectionRepo.ListFavorites(ctx, userID)
+	if err != nil {
+		return domain.RoleList{}, err
+	}
+	return domain.RoleList{Roles: lo.Map(roles, func(r domain.Role, _ int) domain.RoleWithoutPrompt { return r.Public() })}, nil
+}
+
+func (u *CollectionUsecase) ListRecentRoles(ctx context.Context, userID string) (domain.RoleList, error) {
+	roles, err := u.collectionRepo.ListRecentRoles(ctx, userID, recentRolesLimit)
+	if err != nil {
+		return domain.RoleList{}, err
+	}
+	return domain.RoleList{Roles: lo.Map(roles, func(r domain.Role, _ int) domain.RoleWithoutPrompt { return r.Public() })}, nil
+}
+
+// checkRole 只能点赞、收藏自己可见的角色
+func (u *CollectionUsecase) checkRole(ctx context.Context, userID string, roleID int) error {
+	role, err := u.roleRepo.GetroleById(ctx, roleID)
+	if err != nil {
+		return err
+	}
+	if !role.VisibleTo(userID) {
+		return fmt.Errorf("role %d not found", roleID)
+	}
+	return nil
+}
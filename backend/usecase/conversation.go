@@ -0,0 +1,328 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/repo"
+	"demo/usecase/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+	"gorm.io/gorm"
+)
+
+// ConversationUsecase 对话线程的创建、恢复，以及用户查看和管理自己的对话记录
+type ConversationUsecase struct {
+	l                *log.Logger
+	conversationRepo *repo.ConversationMessageRepo
+	roleRepo         *repo.RoleRepo
+	fileUsecase      *FileUsecase
+}
+
+func NewConversationUsecase(l *log.Logger, conversationRepo *repo.ConversationMessageRepo, roleRepo *repo.RoleRepo, file *FileUsecase) *ConversationUsecase {
+	return &ConversationUsecase{
+		l:                l.WithModule("ConversationUsecase"),
+		conversationRepo: conversationRepo,
+		roleRepo:         roleRepo,
+		fileUsecase:      file,
+	}
+}
+
+// conversationMaxTitle 标题最多的字数
+const conversationMaxTitle = 64
+
+// CountTurns 用户在所有对话中一共问过的次数
+func (u *ConversationUsecase) CountTurns(ctx context.Context, userID string) (int64, error) {
+	return u.conversationRepo.CountUserTurns(ctx, userID)
+}
+
+func (u *ConversationUsecase) Create(ctx context.Context, userID string, req domain.CreateConversationReq) (domain.Conversation, error) {
+	role, err := u.roleRepo.GetroleById(ctx, req.RoleID)
+	if err != nil || !role.VisibleTo(userID) {
+		return domain.Conversation{}, fmt.Errorf("role %d not found", req.RoleID)
+	}
+	title := strings.TrimSpace(req.Title)
+	if utf8.RuneCountInString(title) > conversationMaxTitle {
+		return domain.Conversation{}, fmt.Errorf("title exceeds %d characters", conversationMaxTitle)
+	}
+	conv := domain.Conversation{UserID: userID, RoleID: req.RoleID, Title: title}
+	if err := u.conversationRepo.CreateConversation(ctx, &conv); err != nil {
+		return domain.Conversation{}, err
+	}
+	return conv, nil
+}
+
+// Open 建立语音会话时选择对话线程：conversationID 为 0 时继续与该角色最近的对话（没有时才新建），
+// 避免每次打开页面都留下一个空对话；否则恢复用户自己的对话（归档的会被取消归档）
+func (u *ConversationUsecase) Open(ctx context.Context, userID string, roleID, conversationID int) (domain.Conversation, error) {
+	if conversationID == 0 {
+		return u.Latest(ctx, userID, roleID)
+	}
+	conv, err := u.conversationRepo.GetConversation(ctx, userID, conversationID)
+	if err != nil {
+		return conv, err
+	}
+	if conv.Archived {
+		if err := u.conversationRepo.UpdateConversation(ctx, conv.ID, map[string]any{"archived": false}); err != nil {
+			return conv, err
+		}
+		conv.Archived = false
+	}
+	return conv, nil
+}
+
+// Latest 用户与角色最近的对话，没有时新建一个，供不区分对话线程的旧接口使用
+func (u *ConversationUsecase) Latest(ctx context.Context, userID string, roleID int) (domain.Conversation, error) {
+	conv, err := u.conversationRepo.LatestConversation(ctx, userID, roleID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return u.Create(ctx, userID, domain.CreateConversationReq{RoleID: roleID})
+	}
+	return conv, err
+}
+
+func (u *ConversationUsecase) Update(ctx context.Context, userID string, id int, req domain.UpdateConversationReq) (domain.Conversation, error) {
+	conv, err := u.conversationRepo.GetConversation(ctx, userID, id)
+	if err != nil {
+		return conv, err
+	}
+	updates := map[string]any{}
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if utf8.RuneCountInString(title) > conversationMaxTitle {
+			return conv, fmt.Errorf("title exceeds %d characters", conversationMaxTitle)
+		}
+		updates["title"] = title
+		conv.Title = title
+	}
+	if req.Archived != nil {
+		updates["archived"] = *req.Archived
+		conv.Archived = *req.Archived
+	}
+	if len(updates) == 0 {
+		return conv, nil
+	}
+	if err := u.conversationRepo.UpdateConversation(ctx, id, updates); err != nil {
+		return conv, err
+	}
+	return conv, nil
+}
+
+func (u *ConversationUsecase) Delete(ctx context.Context, userID string, id int) error {
+	if _, err := u.conversationRepo.GetConversation(ctx, userID, id); err != nil {
+		return err
+	}
+	keys, err := u.conversationRepo.ListAudioKeys(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := u.conversationRepo.DeleteConversation(ctx, id); err != nil {
+		return err
+	}
+	u.removeAudio(keys)
+	return nil
+}
+
+func (u *ConversationUsecase) ListConversations(ctx context.Context, userID string, q domain.ConversationQuery) (domain.ConversationList, error) {
+	list, err := u.conversationRepo.ListConversations(ctx, userID, q)
+	if err != nil {
+		return domain.ConversationList{}, err
+	}
+	return domain.ConversationList{Conversations: list}, nil
+}
+
+func (u *ConversationUsecase) ListMessages(ctx context.Context, userID string, id, beforeID, limit int) (domain.MessageList, error) {
+	if _, err := u.conversationRepo.GetConversation(ctx, userID, id); err != nil {
+		return domain.MessageList{}, err
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	messages, err := u.conversationRepo.ListMessages(ctx, id, beforeID, limit)
+	if err != nil {
+		return domain.MessageList{}, err
+	}
+	u.fillAudioUrl(messages)
+	list := domain.MessageList{Messages: messages}
+	if len(messages) == limit {
+		list.NextBeforeID = messages[len(messages)-1].ID
+	}
+	return list, nil
+}
+
+func (u *ConversationUsecase) DeleteMessage(ctx context.Context, userID string, id, messageID int) error {
+	if _, err := u.conversationRepo.GetConversation(ctx, userID, id); err != nil {
+		return err
+	}
+	keys, err := u.conversationRepo.DeleteMessage(ctx, id, messageID)
+	if err != nil {
+		return err
+	}
+	u.removeAudio(keys)
+	return nil
+}
+
+func (u *ConversationUsecase) ClearConversation(ctx context.Context, userID string, id int) (domain.ClearConversationResp, error) {
+	if _, err := u.conversationRepo.GetConversation(ctx, userID, id); err != nil {
+		return domain.ClearConversationResp{}, err
+	}
+	keys, err := u.conversationRepo.ListAudioKeys(ctx, id)
+	if err != nil {
+		return domain.ClearConversationResp{}, err
+	}
+	n, err := u.conversationRepo.ClearConversation(ctx, id)
+	if err != nil {
+		return domain.ClearConversationResp{}, err
+	}
+	u.removeAudio(keys)
+	return domain.ClearConversationResp{Deleted: n}, nil
+}
+
+const (
+	// exportAudioGap 导出音频时每条消息之间的静音
+	exportAudioGap = 500 * time.Millisecond
+	// exportAudioMaxBytes 导出音频拼接后 PCM 的上限（16kHz 约 35 分钟），避免长对话占满内存
+	exportAudioMaxBytes = 64 << 20
+)
+
+// ExportFile 按格式渲染导出文件，返回文件内容和 Content-Type
+func (u *ConversationUsecase) ExportFile(ctx context.Context, userID string, id int, format string) ([]byte, string, error) {
+	export, err := u.Export(ctx, userID, id)
+	if err != nil {
+		return nil, "", err
+	}
+	switch format {
+	case "", domain.ExportJSON:
+		b, err := json.MarshalIndent(export, "", "  ")
+		return b, "application/json", err
+	case domain.ExportMarkdown:
+		return renderMarkdown(export), "text/markdown; charset=utf-8", nil
+	case domain.ExportWav:
+		pcm, err := u.concatAudio(ctx, export.Messages)
+		if err != nil {
+			return nil, "", err
+		}
+		return utils.PCMToWav(pcm, utils.TtsSampleRate), "audio/wav", nil
+	case domain.ExportMp3:
+		pcm, err := u.concatAudio(ctx, export.Messages)
+		if err != nil {
+			return nil, "", err
+		}
+		b, err := utils.PCMToMp3(pcm, utils.TtsSampleRate)
+		return b, "audio/mpeg", err
+	default:
+		return nil, "", fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// renderMarkdown 对话记录渲染成 Markdown，每条消息带时间和说话人
+func renderMarkdown(export domain.ConversationExport) []byte {
+	roleName := export.RoleName
+	if roleName == "" {
+		roleName = "角色"
+	}
+	title := export.Title
+	if title == "" {
+		title = "与" + roleName + "的对话"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	fmt.Fprintf(&sb, "- 角色：%s\n- 导出时间：%s\n\n", roleName, export.ExportedAt.Format(time.DateTime))
+	for _, m := range export.Messages {
+		speaker := "我"
+		if m.Role == schema.Assistant {
+			speaker = roleName
+		}
+		fmt.Fprintf(&sb, "**%s** · %s\n\n%s\n\n", speaker, m.Time.Format(time.DateTime), m.Content)
+	}
+	return []byte(sb.String())
+}
+
+// concatAudio 按顺序拼接消息的录音，返回 PCM，中间插入短暂静音；采样率不一致或读取失败的音频跳过。
+// 总长度超过 exportAudioMaxBytes 时返回错误
+func (u *ConversationUsecase) concatAudio(ctx context.Context, messages []domain.ConversationMessage) ([]byte, error) {
+	const sampleRate = utils.TtsSampleRate
+	gap := make([]byte, int(exportAudioGap.Seconds()*sampleRate)*2)
+	var pcm bytes.Buffer
+	for _, m := range messages {
+		if m.AudioKey == "" {
+			continue
+		}
+		wav, err := u.fileUsecase.ReadFile(ctx, m.AudioKey)
+		if err != nil {
+			u.l.Warn("read message audio failed", log.String("key", m.AudioKey), log.Error(err))
+			continue
+		}
+		data, rate, err := utils.ParseWav(wav)
+		if err != nil || rate != sampleRate {
+			u.l.Warn("skip message audio", log.String("key", m.AudioKey), log.Int("rate", rate), log.Error(err))
+			continue
+		}
+		if pcm.Len() > 0 {
+			pcm.Write(gap)
+		}
+		if pcm.Len()+len(data) > exportAudioMaxBytes {
+			return nil, fmt.Errorf("conversation audio exceeds the export limit of %d MB", exportAudioMaxBytes>>20)
+		}
+		pcm.Write(data)
+	}
+	if pcm.Len() == 0 {
+		return nil, errors.New("conversation has no audio")
+	}
+	return pcm.Bytes(), nil
+}
+
+// Export 导出对话；角色已被删除时角色名为空
+func (u *ConversationUsecase) Export(ctx context.Context, userID string, id int) (domain.ConversationExport, error) {
+	conv, err := u.conversationRepo.GetConversation(ctx, userID, id)
+	if err != nil {
+		return domain.ConversationExport{}, err
+	}
+	messages, err := u.conversationRepo.ListVisibleMessages(ctx, id)
+	if err != nil {
+		return domain.ConversationExport{}, err
+	}
+	u.fillAudioUrl(messages)
+	export := domain.ConversationExport{
+		ConversationID: conv.ID,
+		Title:          conv.Title,
+		RoleID:         conv.RoleID,
+		UserID:         userID,
+		ExportedAt:     time.Now(),
+		Messages:       messages,
+	}
+	if role, err := u.roleRepo.GetroleById(ctx, conv.RoleID); err == nil {
+		export.RoleName = role.Name
+	}
+	return export, nil
+}
+
+func (u *ConversationUsecase) fillAudioUrl(messages []domain.ConversationMessage) {
+	for i := range messages {
+		if messages[i].AudioKey != "" {
+			messages[i].AudioUrl = u.fileUsecase.FileUrl(messages[i].AudioKey)
+		}
+	}
+}
+
+// removeAudio 后台删除消息的音频文件，失败只记录日志
+func (u *ConversationUsecase) removeAudio(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		for _, key := range keys {
+			if err := u.fileUsecase.RemoveFile(ctx, key); err != nil {
+				u.l.Warn("remove message audio failed", log.String("key", key), log.Error(err))
+			}
+		}
+	}()
+}
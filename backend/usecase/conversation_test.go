@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"demo/domain"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	at := time.Date(2024, 5, 1, 20, 30, 0, 0, time.Local)
+	md := string(renderMarkdown(domain.ConversationExport{
+		RoleName:   "孔子",
+		ExportedAt: at,
+		Messages: []domain.ConversationMessage{
+			{Role: schema.User, Content: "什么是仁？", Time: at},
+			{Role: schema.Assistant, Content: "仁者爱人。", Time: at.Add(time.Second)},
+		},
+	}))
+	for _, want := range []string{
+		"# 与孔子的对话",
+		"**我** · 2024-05-01 20:30:00\n\n什么是仁？",
+		"**孔子** · 2024-05-01 20:30:01\n\n仁者爱人。",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
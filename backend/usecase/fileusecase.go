@@ -5,6 +5,7 @@ import (
 	"demo/config"
 	"demo/pkg/log"
 	"demo/pkg/store"
+	"fmt"
 	"io"
 	"mime/multipart"
 
@@ -51,3 +52,49 @@ func (u *FileUsecase) UploadFileWithWriter(ctx context.Context, name string, wri
 	}
 	return info.Key, nil
 }
+
+func (u *FileUsecase) RemoveFile(ctx context.Context, key string) error {
+	if err := u.minio.Client.RemoveObject(ctx, u.config.Oss.BucketName, key, minio.RemoveObjectOptions{}); err != nil {
+		u.l.Logger.Error("remove file failed", log.Error(err))
+		return err
+	}
+	return nil
+}
+
+// FileUrl 返回文件的公开访问地址（bucket 为公共读）
+func (u *FileUsecase) FileUrl(key string) string {
+	return fmt.Sprintf("%s/%s/%s", u.config.EndPoint, u.config.Oss.BucketName, key)
+}
+
+// FileExists 判断文件是否已存在
+func (u *FileUsecase) FileExists(ctx context.Context, key string) (bool, error) {
+	_, err := u.minio.Client.StatObject(ctx, u.config.Oss.BucketName, key, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return false, nil
+	}
+	return false, err
+}
+
+// ReadFile 读取整个文件
+func (u *FileUsecase) ReadFile(ctx context.Context, key string) ([]byte, error) {
+	obj, err := u.minio.Client.GetObject(ctx, u.config.Oss.BucketName, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+// RemovePrefix 删除目录下的全部文件
+func (u *FileUsecase) RemovePrefix(ctx context.Context, prefix string) error {
+	objects := u.minio.Client.ListObjects(ctx, u.config.Oss.BucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	for e := range u.minio.Client.RemoveObjects(ctx, u.config.Oss.BucketName, objects, minio.RemoveObjectsOptions{}) {
+		if e.Err != nil {
+			return e.Err
+		}
+	}
+	return nil
+}
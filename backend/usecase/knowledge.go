@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/repo"
+	"demo/usecase/utils"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// knowledgeChunkSize 每个段落的最大字数
+	knowledgeChunkSize = 300
+	// knowledgeMaxFileSize 单个资料文件的大小上限
+	knowledgeMaxFileSize = 2 << 20
+	// knowledgeTopK 每次注入对话的段落数
+	knowledgeTopK = 3
+)
+
+// KnowledgeUsecase 角色知识库：上传资料、切分段落、本地 BM25 检索
+type KnowledgeUsecase struct {
+	l           *log.Logger
+	repo        *repo.KnowledgeRepo
+	roleRepo    *repo.RoleRepo
+	fileUsecase *FileUsecase
+
+	mu      sync.Mutex
+	indexes map[int]*roleIndex // roleID -> 索引，资料变更时失效
+	version int                // 每次失效加一，避免把加载期间已过期的索引写回缓存
+}
+
+type roleIndex struct {
+	chunks []domain.KnowledgeChunk
+	bm25   *utils.BM25
+}
+
+func NewKnowledgeUsecase(l *log.Logger, repo *repo.KnowledgeRepo, roleRepo *repo.RoleRepo, file *FileUsecase) *KnowledgeUsecase {
+	return &KnowledgeUsecase{
+		l:           l.WithModule("KnowledgeUsecase"),
+		repo:        repo,
+		roleRepo:    roleRepo,
+		fileUsecase: file,
+		indexes:     make(map[int]*roleIndex),
+	}
+}
+
+// Upload 保存原文件到 OSS，切分段落入库，并使该角色的索引失效
+func (k *KnowledgeUsecase) Upload(ctx context.Context, roleID int, file *multipart.FileHeader) (*domain.KnowledgeDocument, error) {
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != ".txt" && ext != ".md" {
+		return nil, fmt.Errorf("unsupported file type %q, only .txt and .md are allowed", ext)
+	}
+	if file.Size > knowledgeMaxFileSize {
+		return nil, fmt.Errorf("file too large: %d bytes", file.Size)
+	}
+	if _, err := k.roleRepo.GetroleById(ctx, roleID); err != nil {
+		return nil, fmt.Errorf("role %d not found: %w", roleID, err)
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(io.LimitReader(f, knowledgeMaxFileSize))
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(content) {
+		return nil, errors.New("file must be UTF-8 encoded text")
+	}
+	chunks := utils.ChunkText(string(content), knowledgeChunkSize)
+	if len(chunks) == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	name := fmt.Sprintf("knowledge/%d/%s%s", roleID, uuid.New().String(), ext)
+	key, err := k.fileUsecase.UploadFileWithWriter(ctx, name, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+	doc := &domain.KnowledgeDocument{RoleID: roleID, Name: file.Filename, FileKey: key}
+	if err := k.repo.CreateDocument(ctx, doc, chunks); err != nil {
+		_ = k.fileUsecase.RemoveFile(ctx, key)
+		return nil, err
+	}
+	k.invalidate(roleID)
+	k.l.Info("knowledge document uploaded", log.Int("role_id", roleID), log.String("name", file.Filename), log.Int("chunks", len(chunks)))
+	return doc, nil
+}
+
+func (k *KnowledgeUsecase) ListDocuments(ctx context.Context, roleID int) ([]domain.KnowledgeDocument, error) {
+	return k.repo.ListDocuments(ctx, roleID)
+}
+
+func (k *KnowledgeUsecase) DeleteDocument(ctx context.Context, roleID, id int) error {
+	doc, err := k.repo.GetDocument(ctx, roleID, id)
+	if err != nil {
+		return err
+	}
+	if err := k.repo.DeleteDocument(ctx, roleID, id); err != nil {
+		return err
+	}
+	k.invalidate(roleID)
+	if err := k.fileUsecase.RemoveFile(ctx, doc.FileKey); err != nil {
+		k.l.Warn("remove knowledge file failed", log.String("key", doc.FileKey), log.Error(err))
+	}
+	return nil
+}
+
+// Search 返回与问题最相关的 topK 个段落，角色没有资料时返回空
+func (k *KnowledgeUsecase) Search(ctx context.Context, roleID int, query string, topK int) ([]domain.KnowledgePassage, error) {
+	idx, err := k.index(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	var passages []domain.KnowledgePassage
+	for _, hit := range idx.bm25.Search(query, topK) {
+		c := idx.chunks[hit.Index]
+		passages = append(passages, domain.KnowledgePassage{DocumentID: c.DocumentID, Content: c.Content, Score: hit.Score})
+	}
+	return passages, nil
+}
+
+func (k *KnowledgeUsecase) index(ctx context.Context, roleID int) (*roleIndex, error) {
+	k.mu.Lock()
+	idx, ok := k.indexes[roleID]
+	version := k.version
+	k.mu.Unlock()
+	if ok {
+		return idx, nil
+	}
+	chunks, err := k.repo.ListChunks(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Content
+	}
+	idx = &roleIndex{chunks: chunks, bm25: utils.NewBM25(texts)}
+	k.mu.Lock()
+	if k.version == version {
+		k.indexes[roleID] = idx
+	}
+	k.mu.Unlock()
+	return idx, nil
+}
+
+func (k *KnowledgeUsecase) invalidate(roleID int) {
+	k.mu.Lock()
+	delete(k.indexes, roleID)
+	k.version++
+	k.mu.Unlock()
+}
@@ -6,9 +6,18 @@ import (
 	"demo/domain"
 	"demo/pkg/log"
 	"demo/repo"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
 
 	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
 )
 
@@ -18,75 +27,505 @@ type LlmUsecase struct {
 	config           *config.Config
 	conversationRepo *repo.ConversationMessageRepo
 	rolerepo         *repo.RoleRepo
+	knowledge        *KnowledgeUsecase
 }
 
 // NewLlmUsecase 创建LlmUsecase实例
-func NewLlmUsecase(l *log.Logger, c *config.Config, conversationRepo *repo.ConversationMessageRepo, rolerepo *repo.RoleRepo) *LlmUsecase {
+func NewLlmUsecase(l *log.Logger, c *config.Config, conversationRepo *repo.ConversationMessageRepo, rolerepo *repo.RoleRepo, knowledge *KnowledgeUsecase) *LlmUsecase {
 	return &LlmUsecase{
 		l:                l.WithModule("LlmUsecase"),
 		config:           c,
 		conversationRepo: conversationRepo,
 		rolerepo:         rolerepo,
+		knowledge:        knowledge,
 	}
 }
 
-// Chat 调用七牛云LLM API进行对话
+// maxToolRounds 单轮对话中最多执行几轮工具调用，最后一轮不再提供工具以保证产生回答
+const maxToolRounds = 3
+
+// ChatReply 一次对话回复：Tokens 为流式输出，Model 为实际应答的模型，
+// ToolMessages 为产生回答前的工具调用及结果（按顺序）
+type ChatReply struct {
+	Tokens       <-chan string
+	Model        string
+	ToolMessages []*schema.Message
+	// Usage 模型返回的 token 用量，Tokens 读完后才完整；被打断时模型不会返回用量，按已发送的内容估算
+	Usage TokenUsage
+}
+
+// TokenUsage 累计一次回答（包括工具调用轮次）消耗的 token
+type TokenUsage struct {
+	Prompt     atomic.Int64
+	Completion atomic.Int64
+	done       chan struct{} //最后一轮输出结束时关闭
+}
+
+// add 流式输出时用量在最后一个分片的 ResponseMeta 中，返回该分片是否带有用量
+func (u *TokenUsage) add(msg *schema.Message) bool {
+	if msg == nil || msg.ResponseMeta == nil || msg.ResponseMeta.Usage == nil {
+		return false
+	}
+	u.Prompt.Add(int64(msg.ResponseMeta.Usage.PromptTokens))
+	u.Completion.Add(int64(msg.ResponseMeta.Usage.CompletionTokens))
+	return true
+}
+
+// Wait 等待回答的输出结束（此后用量不再变化），最多等待 timeout
+func (u *TokenUsage) Wait(timeout time.Duration) {
+	if u.done == nil {
+		return
+	}
+	select {
+	case <-u.done:
+	case <-time.After(timeout):
+	}
+}
+
+// roundUsage 统计一次模型调用的用量：模型没有返回用量时（如被打断），按输入消息和已输出的内容估算
+type roundUsage struct {
+	usage    *TokenUsage
+	reported bool
+	output   strings.Builder
+}
+
+func (r *roundUsage) add(msg *schema.Message) {
+	if r.usage.add(msg) {
+		r.reported = true
+	}
+	r.output.WriteString(msg.Content)
+	for _, tc := range msg.ToolCalls {
+		r.output.WriteString(tc.Function.Arguments)
+	}
+}
+
+func (r *roundUsage) finish(messages []*schema.Message) {
+	if r.reported {
+		return
+	}
+	var prompt int64
+	for _, m := range messages {
+		// 每条消息另有角色等固定开销
+		prompt += estimateTokens(m.Content) + 4
+	}
+	r.usage.Prompt.Add(prompt)
+	r.usage.Completion.Add(estimateTokens(r.output.String()))
+}
+
+// estimateTokens 粗略估算 token 数：汉字等 CJK 字符一个字算一个，其余约四个字符一个
+func estimateTokens(s string) int64 {
+	var cjk, other int64
+	for _, r := range s {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
 
+// Chat 调用七牛云LLM API进行对话
 func (l *LlmUsecase) Chat(ctx context.Context, messages []*schema.Message) (<-chan string, error) {
-	chatConfig := &openai.ChatModelConfig{
-		APIKey:  l.config.Asr.ApiKey,
-		BaseURL: l.config.Asr.BaseUrl,
-		Model:   "deepseek-v3",
+	reply, err := l.Reply(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Tokens, nil
+}
+
+// Reply 按配置顺序依次尝试模型，首个 token 超时或出错时回退到下一个模型；
+// 传入工具时模型可以先调用工具，工具在服务端执行后再生成最终回答
+// 回退前失败的调用消耗的用量累加到最终的回复上
+func (l *LlmUsecase) Reply(ctx context.Context, messages []*schema.Message, tools ...tool.BaseTool) (*ChatReply, error) {
+	var lastErr error
+	var failedPrompt, failedCompletion int64
+	for _, m := range l.config.Llm.Models {
+		reply, err := l.reply(ctx, m, messages, tools)
+		if err == nil {
+			reply.Usage.Prompt.Add(failedPrompt)
+			reply.Usage.Completion.Add(failedCompletion)
+			return reply, nil
+		}
+		if reply != nil {
+			failedPrompt += reply.Usage.Prompt.Load()
+			failedCompletion += reply.Usage.Completion.Load()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		l.l.Warn("llm model failed, fallback", log.String("model", m.Model), log.Error(err))
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no llm model configured")
+	}
+	return nil, fmt.Errorf("all llm models failed: %w", lastErr)
+}
+
+// reply 使用单个模型完成一次回答，包括中间的工具调用轮次；出错时返回的 reply 不为空时带有已消耗的用量
+func (l *LlmUsecase) reply(ctx context.Context, m config.LlmModelConfig, messages []*schema.Message, tools []tool.BaseTool) (*ChatReply, error) {
+	chatModel, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
+		APIKey:  m.ApiKey,
+		BaseURL: m.BaseUrl,
+		Model:   m.Model,
+	})
+	if err != nil {
+		return nil, err
+	}
+	reply := &ChatReply{Model: m.Model}
+	reply.Usage.done = make(chan struct{})
+	if len(tools) == 0 {
+		reply.Tokens, _, err = l.stream(ctx, m.Model, chatModel, messages, &reply.Usage)
+		return reply, err
 	}
-	chatModel, err := openai.NewChatModel(ctx, chatConfig)
+
+	infos := make([]*schema.ToolInfo, 0, len(tools))
+	for _, t := range tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	toolModel, err := chatModel.WithTools(infos)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := chatModel.Stream(ctx, messages)
+	toolsNode, err := compose.NewToolNode(ctx, &compose.ToolsNodeConfig{
+		Tools: tools,
+		UnknownToolsHandler: func(ctx context.Context, name, input string) (string, error) {
+			return "工具不存在：" + name, nil
+		},
+	})
 	if err != nil {
 		return nil, err
 	}
+
+	history := append([]*schema.Message{}, messages...)
+	for round := 0; ; round++ {
+		var cm model.BaseChatModel = toolModel
+		if round == maxToolRounds {
+			cm = chatModel
+		}
+		tokens, call, err := l.stream(ctx, m.Model, cm, history, &reply.Usage)
+		if err != nil {
+			return reply, err
+		}
+		if call == nil {
+			reply.Tokens = tokens
+			return reply, nil
+		}
+		results, err := toolsNode.Invoke(ctx, call)
+		if err != nil {
+			return reply, fmt.Errorf("invoke tools: %w", err)
+		}
+		for _, tc := range call.ToolCalls {
+			l.l.Info("llm tool call", log.String("tool", tc.Function.Name), log.String("arguments", tc.Function.Arguments))
+		}
+		reply.ToolMessages = append(reply.ToolMessages, call)
+		reply.ToolMessages = append(reply.ToolMessages, results...)
+		history = append(history, call)
+		history = append(history, results...)
+	}
+}
+
+// stream 调用一次模型并等待首个有效输出（超时视为失败）：
+// 输出文本时返回后续 token 的 channel；输出工具调用时读完整个流并返回合并后的工具调用消息
+func (l *LlmUsecase) stream(ctx context.Context, modelName string, cm model.BaseChatModel, messages []*schema.Message, usage *TokenUsage) (<-chan string, *schema.Message, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	// 超时回调在另一个 goroutine 执行：只有仍在等待首个输出时才取消，收到首个输出后不再生效
+	var state atomic.Int32 // 0 等待首个输出，1 已收到，2 已超时
+	timer := time.AfterFunc(time.Duration(l.config.Llm.FirstTokenTimeout)*time.Millisecond, func() {
+		if state.CompareAndSwap(0, 2) {
+			cancel()
+		}
+	})
+	gotFirst := func() bool {
+		timer.Stop()
+		return state.CompareAndSwap(0, 1)
+	}
+
+	resp, err := cm.Stream(streamCtx, messages)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	round := &roundUsage{usage: usage}
+	first := ""
+	for first == "" {
+		msg, err := resp.Recv()
+		if err == io.EOF {
+			err = errors.New("empty response")
+		}
+		if err != nil {
+			resp.Close()
+			cancel()
+			// 请求已经发出，失败的调用同样计入用量
+			round.finish(messages)
+			if streamCtx.Err() != nil && ctx.Err() == nil {
+				return nil, nil, fmt.Errorf("first token timeout: %w", err)
+			}
+			return nil, nil, err
+		}
+		round.add(msg)
+		if len(msg.ToolCalls) > 0 {
+			if !gotFirst() {
+				resp.Close()
+				cancel()
+				round.finish(messages)
+				return nil, nil, errors.New("first token timeout")
+			}
+			call, err := l.drainToolCall(resp, msg, round)
+			resp.Close()
+			cancel()
+			round.finish(messages)
+			return nil, call, err
+		}
+		first = msg.Content
+	}
+	if !gotFirst() {
+		resp.Close()
+		cancel()
+		round.finish(messages)
+		return nil, nil, errors.New("first token timeout")
+	}
+
 	ch := make(chan string)
 	go func() {
+		defer close(ch)
+		defer func() {
+			round.finish(messages)
+			if usage.done != nil {
+				close(usage.done)
+			}
+		}()
+		defer cancel()
+		defer resp.Close()
+		content := first
 		for {
-			msg, err := resp.Recv()
-			if err == io.EOF {
-				break
+			if content != "" {
+				select {
+				case ch <- content:
+				case <-streamCtx.Done():
+					return
+				}
 			}
+			msg, err := resp.Recv()
 			if err != nil {
-				break
+				if err != io.EOF {
+					l.l.Error("llm stream recv failed", log.String("model", modelName), log.Error(err))
+				}
+				return
 			}
 			l.l.Info("receive message", log.String("message", msg.Content))
-			ch <- msg.Content
+			round.add(msg)
+			content = msg.Content
 		}
-		close(ch)
 	}()
-	return ch, err
+	return ch, nil, nil
+}
+
+// drainToolCall 读完剩余的流式分片并合并成一条带 ToolCalls 的 assistant 消息
+func (l *LlmUsecase) drainToolCall(resp *schema.StreamReader[*schema.Message], first *schema.Message, round *roundUsage) (*schema.Message, error) {
+	chunks := []*schema.Message{first}
+	for {
+		msg, err := resp.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		round.add(msg)
+		chunks = append(chunks, msg)
+	}
+	return schema.ConcatMessages(chunks)
+}
+
+// TurnAudio 一轮对话中用户提问的录音和角色回答的合成语音
+type TurnAudio struct {
+	Question domain.MessageAudio
+	Answer   domain.MessageAudio
+}
+
+// SaveTurn 保存一轮对话：用户问题、工具调用过程与模型回答，回答上记录实际应答的模型
+func (l *LlmUsecase) SaveTurn(ctx context.Context, conv domain.Conversation, question, answer string, reply *ChatReply, audio TurnAudio) error {
+	msgs := []domain.ConversationMessage{{
+		RoleID:         conv.RoleID,
+		UserID:         conv.UserID,
+		ConversationID: conv.ID,
+		Role:           schema.User,
+		Content:        question,
+		MessageAudio:   audio.Question,
+		Time:           time.Now(),
+	}}
+	for _, m := range reply.ToolMessages {
+		msgs = append(msgs, domain.ConversationMessage{
+			RoleID:         conv.RoleID,
+			UserID:         conv.UserID,
+			ConversationID: conv.ID,
+			Role:           m.Role,
+			Content:        m.Content,
+			Model:          reply.Model,
+			ToolCalls:      m.ToolCalls,
+			ToolCallID:     m.ToolCallID,
+			ToolName:       m.ToolName,
+			Time:           time.Now(),
+		})
+	}
+	msgs = append(msgs, domain.ConversationMessage{
+		RoleID:         conv.RoleID,
+		UserID:         conv.UserID,
+		ConversationID: conv.ID,
+		Role:           schema.Assistant,
+		Content:        answer,
+		Model:          reply.Model,
+		MessageAudio:   audio.Answer,
+		Time:           time.Now(),
+	})
+	for _, m := range msgs {
+		if err := l.conversationRepo.CreateMessage(ctx, m); err != nil {
+			return err
+		}
+	}
+	return l.conversationRepo.TouchConversation(ctx, conv.ID, domain.DefaultConversationTitle(question))
+}
+
+// SaveGreeting 保存角色主动说的开场白（没有对应的用户消息）
+func (l *LlmUsecase) SaveGreeting(ctx context.Context, conv domain.Conversation, text, model string, audio domain.MessageAudio) error {
+	err := l.conversationRepo.CreateMessage(ctx, domain.ConversationMessage{
+		RoleID:         conv.RoleID,
+		UserID:         conv.UserID,
+		ConversationID: conv.ID,
+		Role:           schema.Assistant,
+		Content:        text,
+		Model:          model,
+		MessageAudio:   audio,
+		Time:           time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return l.conversationRepo.TouchConversation(ctx, conv.ID, "")
+}
+
+// greetingHistory 生成欢迎回来时参考的最近消息条数
+const greetingHistory = 6
+
+// Greeting 按角色的开场方式生成开场白，不需要开场时返回 nil；欢迎回来只参考当前对话线程的历史
+func (l *LlmUsecase) Greeting(ctx context.Context, conv domain.Conversation, role domain.Role) (*ChatReply, error) {
+	fixed := func() *ChatReply {
+		if role.Persona.Greeting == "" {
+			return nil
+		}
+		ch := make(chan string, 1)
+		ch <- role.Persona.Greeting
+		close(ch)
+		return &ChatReply{Tokens: ch}
+	}
+	switch role.GreetingMode {
+	case domain.GreetingFixed:
+		return fixed(), nil
+	case domain.GreetingWelcomeBack:
+	default:
+		return nil, nil
+	}
+
+	if conv.ID == 0 {
+		return fixed(), nil
+	}
+	history, err := l.conversationRepo.GetMessagesByConversationID(ctx, conv.ID)
+	if err != nil {
+		return nil, err
+	}
+	// 从后往前取最近几条用户和角色的发言
+	var recent []string
+	for i := len(history) - 1; i >= 0 && len(recent) < greetingHistory; i-- {
+		m := history[i]
+		switch {
+		case m.Content == "":
+		case m.Role == schema.User:
+			recent = append(recent, "用户："+m.Content)
+		case m.Role == schema.Assistant:
+			recent = append(recent, "你："+m.Content)
+		}
+	}
+	if len(recent) == 0 {
+		return fixed(), nil
+	}
+	var sb strings.Builder
+	sb.WriteString(domain.WelcomeBackPrompt)
+	for i := len(recent) - 1; i >= 0; i-- {
+		sb.WriteString(recent[i])
+		sb.WriteString("\n")
+	}
+	return l.Reply(ctx, []*schema.Message{
+		schema.SystemMessage(rolePrompt(role)),
+		schema.SystemMessage(domain.VoicePromot),
+		schema.SystemMessage(sb.String()),
+	})
+}
+
+// RoleTools 创建角色声明的工具
+func (l *LlmUsecase) RoleTools(role domain.Role) (*TurnTools, error) {
+	return NewTurnTools(role, l.knowledge)
 }
 
-func (l *LlmUsecase) FormatMessage(ctx context.Context, userid string, roleid int, question string) ([]*schema.Message, error) {
-	messages, err := l.conversationRepo.GetMessagesByUserIDAndRoleID(ctx, userid, roleid)
+// FormatMessage 组装发给模型的消息，历史只取当前对话线程
+func (l *LlmUsecase) FormatMessage(ctx context.Context, conv domain.Conversation, question string) ([]*schema.Message, error) {
+	messages, err := l.conversationRepo.GetMessagesByConversationID(ctx, conv.ID)
 	if err != nil {
 		l.l.Error("error get messgaes", log.Error(err))
 		return nil, err
 	}
-	role, err := l.rolerepo.GetroleById(ctx, roleid)
+	role, err := l.rolerepo.GetroleById(ctx, conv.RoleID)
 
 	var formattedMessages []*schema.Message
 	formattedMessages = append(formattedMessages, &schema.Message{
 		Role:    schema.System,
-		Content: role.Prompt,
+		Content: rolePrompt(role),
 	},
 		&schema.Message{
 			Role:    schema.System,
 			Content: domain.VoicePromot,
 		},
 	)
+	// 从角色知识库检索与问题相关的段落
+	passages, err := l.knowledge.Search(ctx, conv.RoleID, question, knowledgeTopK)
+	if err != nil {
+		l.l.Warn("search knowledge failed", log.Error(err))
+	}
+	if len(passages) > 0 {
+		var sb strings.Builder
+		sb.WriteString(domain.KnowledgePrompt)
+		for i, p := range passages {
+			fmt.Fprintf(&sb, "%d. %s\n", i+1, p.Content)
+		}
+		formattedMessages = append(formattedMessages, &schema.Message{
+			Role:    schema.System,
+			Content: sb.String(),
+		})
+	}
+	// 设定中的示例对话作为 few-shot，放在真实历史之前
+	for _, e := range role.Persona.Examples {
+		formattedMessages = append(formattedMessages, schema.UserMessage(e.User), schema.AssistantMessage(e.Assistant, nil))
+	}
 	for _, m := range messages {
 		if m.Role == schema.Assistant {
 			formattedMessages = append(formattedMessages, &schema.Message{
-				Role:    schema.Assistant,
-				Content: m.Content,
+				Role:      schema.Assistant,
+				Content:   m.Content,
+				ToolCalls: m.ToolCalls,
+			})
+			continue
+		}
+		if m.Role == schema.Tool {
+			formattedMessages = append(formattedMessages, &schema.Message{
+				Role:       schema.Tool,
+				Content:    m.Content,
+				ToolCallID: m.ToolCallID,
+				ToolName:   m.ToolName,
 			})
 			continue
 		}
@@ -104,3 +543,15 @@ func (l *LlmUsecase) FormatMessage(ctx context.Context, userid string, roleid in
 	})
 	return formattedMessages, nil
 }
+
+// rolePrompt 合并自由文本的 Prompt 和结构化设定
+func rolePrompt(role domain.Role) string {
+	if role.Persona.IsZero() {
+		return role.Prompt
+	}
+	prompt := role.Persona.SystemPrompt(role.Name)
+	if role.Prompt != "" {
+		prompt = role.Prompt + "\n" + prompt
+	}
+	return prompt
+}
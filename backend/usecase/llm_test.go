@@ -0,0 +1,241 @@
+package usecase
+
+import (
+	"context"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// newFakeOpenAI 本地的 OpenAI 兼容服务，delay 为首个 token 前的等待时间，status 非 200 时直接返回错误
+func newFakeOpenAI(t *testing.T, delay time.Duration, status int, tokens ...string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if status != http.StatusOK {
+			http.Error(w, `{"error":{"message":"unavailable"}}`, status)
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, tk := range tokens {
+			chunk := map[string]any{
+				"id":      "chatcmpl-test",
+				"object":  "chat.completion.chunk",
+				"created": time.Now().Unix(),
+				"model":   req.Model,
+				"choices": []map[string]any{{
+					"index": 0,
+					"delta": map[string]string{"role": "assistant", "content": tk},
+				}},
+			}
+			b, _ := json.Marshal(chunk)
+			fmt.Fprintf(w, "data: %s\n\n", b)
+			flusher.Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		flusher.Flush()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestLlmUsecase(models ...config.LlmModelConfig) *LlmUsecase {
+	c := &config.Config{}
+	c.Llm.Models = models
+	c.Llm.FirstTokenTimeout = 300
+	return NewLlmUsecase(log.NewLogger(c), c, nil, nil, nil)
+}
+
+func collect(ch <-chan string) string {
+	var sb strings.Builder
+	for tk := range ch {
+		sb.WriteString(tk)
+	}
+	return sb.String()
+}
+
+func TestReplyFallbackOnFirstTokenTimeout(t *testing.T) {
+	slow := newFakeOpenAI(t, 2*time.Second, http.StatusOK, "太慢了")
+	fast := newFakeOpenAI(t, 0, http.StatusOK, "你好", "，", "世界")
+	l := newTestLlmUsecase(
+		config.LlmModelConfig{Model: "slow", BaseUrl: slow.URL, ApiKey: "sk-test"},
+		config.LlmModelConfig{Model: "fast", BaseUrl: fast.URL, ApiKey: "sk-test"},
+	)
+
+	start := time.Now()
+	reply, err := l.Reply(context.Background(), []*schema.Message{schema.UserMessage("你好")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Model != "fast" {
+		t.Errorf("model = %q, want fast", reply.Model)
+	}
+	if got := collect(reply.Tokens); got != "你好，世界" {
+		t.Errorf("content = %q", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fallback took %v", elapsed)
+	}
+}
+
+func TestReplyFallbackOnError(t *testing.T) {
+	down := newFakeOpenAI(t, 0, http.StatusServiceUnavailable)
+	ok := newFakeOpenAI(t, 0, http.StatusOK, "在的")
+	l := newTestLlmUsecase(
+		config.LlmModelConfig{Model: "down", BaseUrl: down.URL, ApiKey: "sk-test"},
+		config.LlmModelConfig{Model: "ok", BaseUrl: ok.URL, ApiKey: "sk-test"},
+	)
+
+	reply, err := l.Reply(context.Background(), []*schema.Message{schema.UserMessage("在吗")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Model != "ok" {
+		t.Errorf("model = %q, want ok", reply.Model)
+	}
+	if got := collect(reply.Tokens); got != "在的" {
+		t.Errorf("content = %q", got)
+	}
+}
+
+func TestReplyAllModelsFailed(t *testing.T) {
+	down := newFakeOpenAI(t, 0, http.StatusInternalServerError)
+	empty := newFakeOpenAI(t, 0, http.StatusOK)
+	l := newTestLlmUsecase(
+		config.LlmModelConfig{Model: "down", BaseUrl: down.URL, ApiKey: "sk-test"},
+		config.LlmModelConfig{Model: "empty", BaseUrl: empty.URL, ApiKey: "sk-test"},
+	)
+
+	if _, err := l.Reply(context.Background(), []*schema.Message{schema.UserMessage("在吗")}); err == nil {
+		t.Fatal("expected error when every model fails")
+	}
+}
+
+func TestReplyWithToolCall(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Role string `json:"role"`
+			} `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		delta := map[string]any{
+			"role": "assistant",
+			"tool_calls": []map[string]any{{
+				"index":    0,
+				"id":       "call_1",
+				"type":     "function",
+				"function": map[string]string{"name": "roll_dice", "arguments": `{"sides":6,"count":2}`},
+			}},
+		}
+		if req.Messages[len(req.Messages)-1].Role == "tool" {
+			delta = map[string]any{"role": "assistant", "content": "骰子已经掷好了"}
+		}
+		b, _ := json.Marshal(map[string]any{
+			"id":      "chatcmpl-test",
+			"object":  "chat.completion.chunk",
+			"model":   req.Model,
+			"choices": []map[string]any{{"index": 0, "delta": delta}},
+		})
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", b)
+	}))
+	t.Cleanup(srv.Close)
+	l := newTestLlmUsecase(config.LlmModelConfig{Model: "tool", BaseUrl: srv.URL, ApiKey: "sk-test"})
+	tools, err := NewTurnTools(domain.Role{Tools: []string{domain.ToolRollDice, domain.ToolEndConversation}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := l.Reply(context.Background(), []*schema.Message{schema.UserMessage("帮我掷两个骰子")}, tools.Tools...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(reply.Tokens); got != "骰子已经掷好了" {
+		t.Errorf("content = %q", got)
+	}
+	if len(reply.ToolMessages) != 2 {
+		t.Fatalf("tool messages = %d, want 2", len(reply.ToolMessages))
+	}
+	if call := reply.ToolMessages[0]; call.Role != schema.Assistant || len(call.ToolCalls) != 1 || call.ToolCalls[0].Function.Name != domain.ToolRollDice {
+		t.Errorf("unexpected tool call message: %+v", call)
+	}
+	if res := reply.ToolMessages[1]; res.Role != schema.Tool || res.ToolCallID != "call_1" || !strings.Contains(res.Content, "总和") {
+		t.Errorf("unexpected tool result message: %+v", res)
+	}
+	if tools.Ended() {
+		t.Error("conversation ended without calling end_conversation")
+	}
+}
+
+func TestReplyEstimatesUsageWhenNotReported(t *testing.T) {
+	srv := newFakeOpenAI(t, 0, http.StatusOK, "你好", "，", "world")
+	l := newTestLlmUsecase(config.LlmModelConfig{Model: "m", BaseUrl: srv.URL, ApiKey: "sk-test"})
+
+	reply, err := l.Reply(context.Background(), []*schema.Message{schema.UserMessage("你好")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(reply.Tokens)
+	reply.Usage.Wait(time.Second)
+	// 输入：2 个汉字 + 每条消息 4；输出：2 个汉字 + "，world" 6 个字符约 2 个
+	if p, c := reply.Usage.Prompt.Load(), reply.Usage.Completion.Load(); p != 6 || c != 4 {
+		t.Errorf("usage = %d/%d, want 6/4", p, c)
+	}
+}
+
+func TestReplyCountsUsageOfFailedAttempts(t *testing.T) {
+	empty := newFakeOpenAI(t, 0, http.StatusOK)
+	ok := newFakeOpenAI(t, 0, http.StatusOK, "在的")
+	l := newTestLlmUsecase(
+		config.LlmModelConfig{Model: "empty", BaseUrl: empty.URL, ApiKey: "sk-test"},
+		config.LlmModelConfig{Model: "ok", BaseUrl: ok.URL, ApiKey: "sk-test"},
+	)
+
+	reply, err := l.Reply(context.Background(), []*schema.Message{schema.UserMessage("你好")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(reply.Tokens)
+	reply.Usage.Wait(time.Second)
+	// 两次调用的输入各 6，只有第二次有输出
+	if p, c := reply.Usage.Prompt.Load(), reply.Usage.Completion.Load(); p != 12 || c != 2 {
+		t.Errorf("usage = %d/%d, want 12/2", p, c)
+	}
+}
+
+func TestNewTurnToolsKnowsAllRoleTools(t *testing.T) {
+	tools, err := NewTurnTools(domain.Role{Tools: domain.RoleTools}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools.Tools) != len(domain.RoleTools) {
+		t.Errorf("got %d tools, want %d", len(tools.Tools), len(domain.RoleTools))
+	}
+	if err := (domain.Role{Name: "r", Prompt: "p", Tools: []string{"fly"}}).Validate(); !errors.Is(err, domain.ErrUnknownTool) {
+		t.Errorf("validate unknown tool: %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/repo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Moderator 审核一段文本，返回 nil 表示通过
+type Moderator interface {
+	Name() string
+	Moderate(ctx context.Context, dir domain.ModerationDirection, text string) (*domain.ModerationResult, error)
+}
+
+// KeywordModerator 本地关键词/正则审核
+type KeywordModerator struct {
+	rules []keywordRule
+}
+
+type keywordRule struct {
+	domain.ModerationRule
+	re *regexp.Regexp
+}
+
+// NewKeywordModerator 编译全部规则，无效的规则被跳过并通过 error 返回，其余规则照常生效
+func NewKeywordModerator(rules []domain.ModerationRule) (*KeywordModerator, error) {
+	m := &KeywordModerator{}
+	var errs []error
+	for _, r := range rules {
+		pattern := r.Pattern
+		if !r.Regex {
+			pattern = regexp.QuoteMeta(pattern)
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid moderation rule %q: %w", r.Pattern, err))
+			continue
+		}
+		if r.Replacement == "" {
+			r.Replacement = "*"
+		}
+		m.rules = append(m.rules, keywordRule{ModerationRule: r, re: re})
+	}
+	return m, errors.Join(errs...)
+}
+
+func (m *KeywordModerator) Name() string {
+	return "keyword"
+}
+
+// Moderate 依次匹配所有规则：rewrite 规则累积替换，block 规则立即返回
+func (m *KeywordModerator) Moderate(_ context.Context, dir domain.ModerationDirection, text string) (*domain.ModerationResult, error) {
+	var result *domain.ModerationResult
+	for _, r := range m.rules {
+		if r.Direction != "" && r.Direction != dir {
+			continue
+		}
+		if !r.re.MatchString(text) {
+			continue
+		}
+		switch r.Action {
+		case domain.ModerationBlock:
+			return &domain.ModerationResult{Action: domain.ModerationBlock, Text: text, Reason: r.Reason}, nil
+		case domain.ModerationRewrite:
+			text = r.re.ReplaceAllLiteralString(text, r.Replacement)
+			result = &domain.ModerationResult{Action: domain.ModerationRewrite, Text: text, Reason: r.Reason}
+		default:
+			if result == nil {
+				result = &domain.ModerationResult{Action: domain.ModerationFlag, Text: text, Reason: r.Reason}
+			}
+		}
+	}
+	if result != nil {
+		result.Text = text
+	}
+	return result, nil
+}
+
+// RemoteModerator 调用远程审核服务：POST {"direction","text"}，返回 domain.ModerationResult
+type RemoteModerator struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func NewRemoteModerator(url, apiKey string) *RemoteModerator {
+	return &RemoteModerator{url: url, apiKey: apiKey, client: &http.Client{Timeout: 3 * time.Second}}
+}
+
+func (m *RemoteModerator) Name() string {
+	return "remote"
+}
+
+func (m *RemoteModerator) Moderate(ctx context.Context, dir domain.ModerationDirection, text string) (*domain.ModerationResult, error) {
+	body, err := json.Marshal(map[string]string{"direction": string(dir), "text": text})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send moderation request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("moderation api returned non-200 status: %s", resp.Status)
+	}
+	var result domain.ModerationResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode moderation response: %w", err)
+	}
+	if result.Action == "" || result.Action == domain.ModerationPass {
+		return nil, nil
+	}
+	if result.Text == "" {
+		result.Text = text
+	}
+	return &result, nil
+}
+
+// ModerationUsecase 串联多个审核器，并记录审核事件
+type ModerationUsecase struct {
+	l          *log.Logger
+	moderators []Moderator
+	repo       *repo.ModerationRepo
+}
+
+func NewModerationUsecase(l *log.Logger, c *config.Config, repo *repo.ModerationRepo) *ModerationUsecase {
+	logger := l.WithModule("ModerationUsecase")
+	rules := append([]domain.ModerationRule{}, domain.DefaultModerationRules...)
+	if c.Moderation.RulesFile != "" {
+		fileRules, err := loadModerationRules(c.Moderation.RulesFile)
+		if err != nil {
+			logger.Error("load moderation rules failed", log.String("file", c.Moderation.RulesFile), log.Error(err))
+		}
+		rules = append(rules, fileRules...)
+	}
+	keyword, err := NewKeywordModerator(rules)
+	if err != nil {
+		logger.Error("some moderation rules rejected", log.Error(err))
+	}
+	m := &ModerationUsecase{
+		l:          logger,
+		moderators: []Moderator{keyword},
+		repo:       repo,
+	}
+	if c.Moderation.RemoteUrl != "" {
+		m.moderators = append(m.moderators, NewRemoteModerator(c.Moderation.RemoteUrl, c.Moderation.RemoteApiKey))
+	}
+	return m
+}
+
+func loadModerationRules(file string) ([]domain.ModerationRule, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var rules []domain.ModerationRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Check 依次执行审核器，rewrite 后的文本交给下一个审核器；出错的审核器跳过（放行）。
+// 非 pass 的结论会记录审核事件
+func (m *ModerationUsecase) Check(ctx context.Context, userID string, roleID int, dir domain.ModerationDirection, text string) *domain.ModerationResult {
+	final := &domain.ModerationResult{Action: domain.ModerationPass, Text: text}
+	for _, mod := range m.moderators {
+		res, err := mod.Moderate(ctx, dir, final.Text)
+		if err != nil {
+			m.l.Warn("moderator failed", log.String("moderator", mod.Name()), log.Error(err))
+			continue
+		}
+		if res == nil {
+			continue
+		}
+		res.Source = mod.Name()
+		m.record(userID, roleID, dir, text, res)
+		if res.Action == domain.ModerationBlock {
+			return res
+		}
+		if res.Action == domain.ModerationRewrite || final.Action == domain.ModerationPass {
+			final = res
+		} else {
+			final.Text = res.Text
+		}
+	}
+	return final
+}
+
+func (m *ModerationUsecase) record(userID string, roleID int, dir domain.ModerationDirection, text string, res *domain.ModerationResult) {
+	m.l.Info("moderation hit", log.String("direction", string(dir)), log.String("action", string(res.Action)), log.String("reason", res.Reason))
+	if m.repo == nil {
+		return
+	}
+	if err := m.repo.CreateEvent(context.Background(), domain.ModerationEvent{
+		UserID:    userID,
+		RoleID:    roleID,
+		Direction: dir,
+		Action:    res.Action,
+		Source:    res.Source,
+		Reason:    res.Reason,
+		Content:   text,
+	}); err != nil {
+		m.l.Error("record moderation event failed", log.Error(err))
+	}
+}
+
+// ModerateStream 逐句审核模型输出：rewrite 替换后继续，block 时改为说拒绝语并丢弃后续内容
+func (m *ModerationUsecase) ModerateStream(ctx context.Context, userID string, roleID int, sentences <-chan string, refusal string) <-chan string {
+	out := make(chan string, 8)
+	go func() {
+		defer close(out)
+		for s := range sentences {
+			res := m.Check(ctx, userID, roleID, domain.ModerationOutput, s)
+			text := res.Text
+			if res.Action == domain.ModerationBlock {
+				text = refusal
+			}
+			select {
+			case out <- text:
+			case <-ctx.Done():
+				return
+			}
+			if res.Action == domain.ModerationBlock {
+				// 排空上游，避免其阻塞
+				for range sentences {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (m *ModerationUsecase) ListEvents(ctx context.Context, beforeID, limit int) ([]domain.ModerationEvent, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return m.repo.ListEvents(ctx, beforeID, limit)
+}
+
+// RefusalFor 返回角色的拒绝语
+func RefusalFor(role domain.Role) string {
+	if r := strings.TrimSpace(role.Refusal); r != "" {
+		return r
+	}
+	return domain.DefaultRefusal
+}
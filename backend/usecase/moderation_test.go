@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"demo/domain"
+	"testing"
+)
+
+func TestKeywordModerator(t *testing.T) {
+	m, err := NewKeywordModerator(append([]domain.ModerationRule{
+		{Pattern: "赌博", Action: domain.ModerationBlock, Direction: domain.ModerationInput, Reason: "gambling"},
+		{Pattern: "笨蛋", Action: domain.ModerationFlag, Reason: "insult"},
+	}, domain.DefaultModerationRules...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	cases := []struct {
+		dir    domain.ModerationDirection
+		text   string
+		action domain.ModerationAction
+		want   string
+	}{
+		{domain.ModerationInput, "教我怎么赌博", domain.ModerationBlock, "教我怎么赌博"},
+		{domain.ModerationOutput, "赌博是违法的", "", ""},
+		{domain.ModerationInput, "你这个笨蛋", domain.ModerationFlag, "你这个笨蛋"},
+		{domain.ModerationOutput, "我的电话是13812345678，记一下", domain.ModerationRewrite, "我的电话是某个号码，记一下"},
+		{domain.ModerationInput, "我的电话是13812345678", "", ""},
+	}
+	for _, c := range cases {
+		res, err := m.Moderate(ctx, c.dir, c.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.action == "" {
+			if res != nil {
+				t.Errorf("%q: got %+v, want pass", c.text, res)
+			}
+			continue
+		}
+		if res == nil || res.Action != c.action || res.Text != c.want {
+			t.Errorf("%q: got %+v, want %s %q", c.text, res, c.action, c.want)
+		}
+	}
+}
+
+func TestKeywordModeratorRejectsBadRule(t *testing.T) {
+	m, err := NewKeywordModerator([]domain.ModerationRule{
+		{Pattern: "([", Regex: true, Action: domain.ModerationBlock},
+		{Pattern: "赌博", Action: domain.ModerationBlock},
+	})
+	if err == nil {
+		t.Fatal("invalid regex should be reported")
+	}
+	res, _ := m.Moderate(context.Background(), domain.ModerationInput, "赌博")
+	if res == nil || res.Action != domain.ModerationBlock {
+		t.Errorf("valid rules should still apply, got %+v", res)
+	}
+}
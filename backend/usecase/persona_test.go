@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+	"demo/domain"
+	"strings"
+	"testing"
+)
+
+func TestRolePrompt(t *testing.T) {
+	if got := rolePrompt(domain.Role{Name: "李白", Prompt: "你是李白"}); got != "你是李白" {
+		t.Errorf("prompt without persona = %q", got)
+	}
+	role := domain.Role{
+		Name:   "李白",
+		Prompt: "你是李白",
+		Persona: domain.Persona{
+			Background:      "唐代诗人，好酒，游历四方",
+			Catchphrases:    []string{"且尽杯中酒"},
+			KnowledgeCutoff: "唐朝天宝年间",
+			TabooTopics:     []string{"政治"},
+		},
+	}
+	got := rolePrompt(role)
+	for _, want := range []string{"你是李白\n", "「李白」", "唐代诗人", "且尽杯中酒", "唐朝天宝年间", "政治"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRoleValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		role domain.Role
+		ok   bool
+	}{
+		{"prompt only", domain.Role{Name: "a", Prompt: "p"}, true},
+		{"persona only", domain.Role{Name: "a", Persona: domain.Persona{Background: "b"}}, true},
+		{"no name", domain.Role{Prompt: "p"}, false},
+		{"empty", domain.Role{Name: "a"}, false},
+		{"half example", domain.Role{Name: "a", Persona: domain.Persona{Examples: []domain.PersonaExample{{User: "你好"}}}}, false},
+		{"empty catchphrase", domain.Role{Name: "a", Prompt: "p", Persona: domain.Persona{Catchphrases: []string{" "}}}, false},
+		{"long greeting", domain.Role{Name: "a", Prompt: "p", Persona: domain.Persona{Greeting: strings.Repeat("好", 201)}}, false},
+	}
+	for _, c := range cases {
+		if err := c.role.Validate(); (err == nil) != c.ok {
+			t.Errorf("%s: err = %v", c.name, err)
+		}
+	}
+}
+
+func TestGreetingFixed(t *testing.T) {
+	l := newTestLlmUsecase()
+	role := domain.Role{Name: "a", Persona: domain.Persona{Greeting: "你来啦"}}
+
+	reply, err := l.Greeting(context.Background(), domain.Conversation{UserID: "u1"}, role)
+	if err != nil || reply != nil {
+		t.Fatalf("greeting without mode = %v, %v", reply, err)
+	}
+	role.GreetingMode = domain.GreetingFixed
+	reply, err = l.Greeting(context.Background(), domain.Conversation{UserID: "u1"}, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(reply.Tokens); got != "你来啦" {
+		t.Errorf("greeting = %q", got)
+	}
+}
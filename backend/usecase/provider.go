@@ -1,9 +1,10 @@
 package usecase
 
 import (
+	"demo/pkg/token"
 	"demo/repo"
 
 	"github.com/google/wire"
 )
 
-var ProviderSet = wire.NewSet(NewUserUsecase, NewRoleUsecase, repo.ProviderSet, NewFileUsecase, NewLlmUsecase, NewWsUsecase, NewAsrUsecase)
+var ProviderSet = wire.NewSet(NewUserUsecase, NewRoleUsecase, repo.ProviderSet, token.ProviderSet, NewFileUsecase, NewLlmUsecase, NewWsUsecase, NewAsrUsecase, NewKnowledgeUsecase, NewModerationUsecase, NewCollectionUsecase, NewVoiceUsecase, NewUserRoleUsecase, NewTtsUsecase, NewTtsCache, NewConversationUsecase, NewUsageUsecase)
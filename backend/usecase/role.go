@@ -3,36 +3,237 @@ package usecase
 import (
 	"context"
 	"demo/domain"
+	"demo/pkg/log"
 	"demo/repo"
+	"demo/usecase/utils"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/samber/lo"
 )
 
 type RoleUsecase interface {
-	ListRoles(ctx context.Context) ([]domain.RoleWithoutPrompt, error)
+	ListRoles(ctx context.Context, query domain.RoleQuery) (domain.RoleList, error)
+	GetRole(ctx context.Context, id int) (domain.Role, error)
+	GetRoleDetail(ctx context.Context, id int) (domain.RoleDetail, error)
+	CreateRole(ctx context.Context, req domain.SaveRoleReq, editor string) (domain.Role, error)
+	UpdateRole(ctx context.Context, id int, req domain.SaveRoleReq, editor string) (domain.Role, error)
+	RollbackRole(ctx context.Context, id, version int, editor string) (domain.Role, error)
+	SetRoleStatus(ctx context.Context, id int, status string) error
+	DeleteRole(ctx context.Context, id int) error
+	ListAllRoles(ctx context.Context) ([]domain.Role, error)
+	ListVersions(ctx context.Context, id int) ([]domain.RoleVersion, error)
+	ListPendingReviews(ctx context.Context) ([]domain.Role, error)
+	ReviewRole(ctx context.Context, id int, req domain.ReviewRoleReq) error
 }
 
 type roleUsecase struct {
-	roleRepo *repo.RoleRepo
+	l           *log.Logger
+	roleRepo    *repo.RoleRepo
+	knowledge   *KnowledgeUsecase
+	fileUsecase *FileUsecase
 }
 
-func NewRoleUsecase(roleRepo *repo.RoleRepo) RoleUsecase {
+func NewRoleUsecase(l *log.Logger, roleRepo *repo.RoleRepo, knowledge *KnowledgeUsecase, file *FileUsecase) RoleUsecase {
 	return &roleUsecase{
-		roleRepo: roleRepo,
+		l:           l.WithModule("RoleUsecase"),
+		roleRepo:    roleRepo,
+		knowledge:   knowledge,
+		fileUsecase: file,
 	}
 }
 
-func (u *roleUsecase) ListRoles(ctx context.Context) ([]domain.RoleWithoutPrompt, error) {
-	roles, err := u.roleRepo.ListRoles(ctx)
+// ListRoles 搜索已发布的角色，结果不包含提示词
+func (u *roleUsecase) ListRoles(ctx context.Context, query domain.RoleQuery) (domain.RoleList, error) {
+	roles, next, err := u.roleRepo.SearchRoles(ctx, query)
 	if err != nil {
-		return nil, err
+		return domain.RoleList{}, err
 	}
 
 	result := lo.Map(roles, func(role domain.Role, _ int) domain.RoleWithoutPrompt {
-		return domain.RoleWithoutPrompt{
-			ID:   role.ID,
-			Name: role.Name,
-		}
+		return role.Public()
 	})
-	return result, nil
+	return domain.RoleList{Roles: result, NextCursor: next}, nil
+}
+
+func (u *roleUsecase) GetRole(ctx context.Context, id int) (domain.Role, error) {
+	return u.roleRepo.GetroleById(ctx, id)
+}
+
+// GetRoleDetail 返回公开角色的详情，并记一次浏览
+func (u *roleUsecase) GetRoleDetail(ctx context.Context, id int) (domain.RoleDetail, error) {
+	role, err := u.roleRepo.GetroleById(ctx, id)
+	if err != nil {
+		return domain.RoleDetail{}, err
+	}
+	if !role.VisibleTo("") {
+		return domain.RoleDetail{}, fmt.Errorf("role %d not found", id)
+	}
+	if err := u.roleRepo.IncrViews(ctx, id); err != nil {
+		return domain.RoleDetail{}, err
+	}
+	role.Views++
+	return domain.RoleDetail{
+		RoleWithoutPrompt: role.Public(),
+		VoiceSampleUrl:    role.VoiceSampleUrl,
+		Greeting:          role.Persona.Greeting,
+	}, nil
+}
+
+// CreateRole 以草稿状态创建角色，并记录版本 1
+func (u *roleUsecase) CreateRole(ctx context.Context, req domain.SaveRoleReq, editor string) (domain.Role, error) {
+	role := domain.Role{Status: domain.RoleDraft, Version: 1}
+	req.Apply(&role)
+	if err := role.Validate(); err != nil {
+		return domain.Role{}, err
+	}
+	version := newRoleVersion(role, domain.Role{}, editor, req.Note)
+	if err := u.roleRepo.CreateRole(ctx, &role, version); err != nil {
+		return domain.Role{}, err
+	}
+	return role, nil
+}
+
+// UpdateRole 修改角色，Prompt 或 Persona 有变化时记录新版本
+func (u *roleUsecase) UpdateRole(ctx context.Context, id int, req domain.SaveRoleReq, editor string) (domain.Role, error) {
+	old, err := u.roleRepo.GetroleById(ctx, id)
+	if err != nil {
+		return domain.Role{}, err
+	}
+	role := old
+	req.Apply(&role)
+	if err := role.Validate(); err != nil {
+		return domain.Role{}, err
+	}
+	versions, err := editVersions(ctx, u.roleRepo, &role, old, editor, req.Note)
+	if err != nil {
+		return domain.Role{}, err
+	}
+	if err := u.roleRepo.UpdateRole(ctx, role, versions...); err != nil {
+		return domain.Role{}, err
+	}
+	return role, nil
+}
+
+// RollbackRole 把 Prompt 和 Persona 恢复到指定版本，回滚本身也记为一个新版本
+func (u *roleUsecase) RollbackRole(ctx context.Context, id, version int, editor string) (domain.Role, error) {
+	old, err := u.roleRepo.GetroleById(ctx, id)
+	if err != nil {
+		return domain.Role{}, err
+	}
+	target, err := u.roleRepo.GetVersion(ctx, id, version)
+	if err != nil {
+		return domain.Role{}, err
+	}
+	role := old
+	role.Prompt = target.Prompt
+	role.Persona = target.Persona
+	if err := role.Validate(); err != nil {
+		return domain.Role{}, err
+	}
+	if roleVersionText(role) == roleVersionText(old) {
+		return old, nil
+	}
+	role.Version++
+	v := newRoleVersion(role, old, editor, fmt.Sprintf("rollback to v%d", version))
+	if err := u.roleRepo.UpdateRole(ctx, role, v); err != nil {
+		return domain.Role{}, err
+	}
+	return role, nil
+}
+
+func (u *roleUsecase) SetRoleStatus(ctx context.Context, id int, status string) error {
+	if status != domain.RoleDraft && status != domain.RolePublished {
+		return fmt.Errorf("unknown role status: %s", status)
+	}
+	return u.roleRepo.UpdateStatus(ctx, id, status)
+}
+
+// DeleteRole 删除角色及其全部数据，OSS 上的录音和知识库文档在后台删除
+func (u *roleUsecase) DeleteRole(ctx context.Context, id int) error {
+	fileKeys, err := u.roleRepo.DeleteRole(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.knowledge.invalidate(id)
+	u.l.Info("role deleted", log.Int("role_id", id), log.Int("files", len(fileKeys)))
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		for _, key := range fileKeys {
+			if err := u.fileUsecase.RemoveFile(ctx, key); err != nil {
+				u.l.Warn("remove file failed", log.String("key", key), log.Error(err))
+			}
+		}
+	}()
+	return nil
+}
+
+func (u *roleUsecase) ListAllRoles(ctx context.Context) ([]domain.Role, error) {
+	return u.roleRepo.ListAllRoles(ctx)
+}
+
+func (u *roleUsecase) ListVersions(ctx context.Context, id int) ([]domain.RoleVersion, error) {
+	return u.roleRepo.ListVersions(ctx, id)
+}
+
+func (u *roleUsecase) ListPendingReviews(ctx context.Context) ([]domain.Role, error) {
+	return u.roleRepo.ListByReviewStatus(ctx, domain.ReviewPending)
+}
+
+// ReviewRole 审核用户公开的角色，通过后出现在角色列表中。只能审核待审核的角色
+func (u *roleUsecase) ReviewRole(ctx context.Context, id int, req domain.ReviewRoleReq) error {
+	role, err := u.roleRepo.GetroleById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if role.Visibility != domain.RolePublic || role.ReviewStatus != domain.ReviewPending {
+		return fmt.Errorf("role %d is not pending review", id)
+	}
+	status := domain.ReviewRejected
+	if req.Approved {
+		status = domain.ReviewApproved
+	}
+	return u.roleRepo.UpdateReview(ctx, id, status, req.Note)
+}
+
+// editVersions 返回一次修改要保存的版本，Prompt 和 Persona 没变化时为空。
+// 角色还没有任何版本时（如初始化写入的角色），先把修改前的状态存为一个版本，保证能回滚
+func editVersions(ctx context.Context, roleRepo *repo.RoleRepo, role *domain.Role, old domain.Role, editor, note string) ([]domain.RoleVersion, error) {
+	if roleVersionText(*role) == roleVersionText(old) {
+		return nil, nil
+	}
+	n, err := roleRepo.CountVersions(ctx, old.ID)
+	if err != nil {
+		return nil, err
+	}
+	var versions []domain.RoleVersion
+	if n == 0 {
+		old.Version = max(old.Version, 1)
+		versions = append(versions, newRoleVersion(old, domain.Role{}, "", "snapshot before first edit"))
+	}
+	role.Version = old.Version + 1
+	return append(versions, newRoleVersion(*role, old, editor, note)), nil
+}
+
+func newRoleVersion(role, old domain.Role, editor, note string) domain.RoleVersion {
+	return domain.RoleVersion{
+		RoleID:  role.ID,
+		Version: role.Version,
+		Prompt:  role.Prompt,
+		Persona: role.Persona,
+		Editor:  editor,
+		Note:    note,
+		Diff:    utils.LineDiff(roleVersionText(old), roleVersionText(role)),
+	}
+}
+
+// roleVersionText 版本比较和 diff 用的文本：Prompt 加上格式化后的 Persona
+func roleVersionText(role domain.Role) string {
+	if role.Persona.IsZero() {
+		return role.Prompt
+	}
+	b, _ := json.MarshalIndent(role.Persona, "", "  ")
+	return role.Prompt + "\n" + string(b)
 }
@@ -0,0 +1,144 @@
+package usecase
+
+import (
+	"context"
+	"demo/domain"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+// TurnTools 一轮对话中角色可调用的工具，end_conversation 被调用后 Ended 返回 true
+type TurnTools struct {
+	Tools     []tool.BaseTool
+	role      domain.Role
+	knowledge *KnowledgeUsecase
+	ended     atomic.Bool
+}
+
+func (t *TurnTools) Ended() bool {
+	return t != nil && t.ended.Load()
+}
+
+type currentTimeArgs struct {
+	Timezone string `json:"timezone,omitempty" jsonschema:"description=IANA 时区，例如 Asia/Shanghai，默认北京时间"`
+}
+
+type rollDiceArgs struct {
+	Sides int `json:"sides,omitempty" jsonschema:"description=骰子面数，默认 6"`
+	Count int `json:"count,omitempty" jsonschema:"description=骰子个数，默认 1，最多 10"`
+}
+
+type knowledgeLookupArgs struct {
+	Query string `json:"query" jsonschema:"description=要查找的关键词或问题"`
+}
+
+type endConversationArgs struct {
+	Reason string `json:"reason,omitempty" jsonschema:"description=结束对话的原因"`
+}
+
+// NewTurnTools 按角色声明的工具名创建工具，未知的工具名返回错误；knowledge 为空时只在角色设定中查找
+func NewTurnTools(role domain.Role, knowledge *KnowledgeUsecase) (*TurnTools, error) {
+	t := &TurnTools{role: role, knowledge: knowledge}
+	for _, name := range role.Tools {
+		var (
+			it  tool.InvokableTool
+			err error
+		)
+		switch name {
+		case domain.ToolCurrentTime:
+			it, err = utils.InferTool(name, "获取当前的日期和时间", t.currentTime)
+		case domain.ToolRollDice:
+			it, err = utils.InferTool(name, "掷骰子，返回每个骰子的点数和总和", t.rollDice)
+		case domain.ToolKnowledgeLookup:
+			it, err = utils.InferTool(name, "在角色资料中查找与问题相关的内容", t.knowledgeLookup)
+		case domain.ToolEndConversation:
+			it, err = utils.InferTool(name, "当用户道别或明确要求结束时调用，说完最后一句话后结束语音对话", t.endConversation)
+		default:
+			return nil, fmt.Errorf("unknown tool: %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		t.Tools = append(t.Tools, it)
+	}
+	return t, nil
+}
+
+func (t *TurnTools) currentTime(_ context.Context, args currentTimeArgs) (string, error) {
+	loc, err := time.LoadLocation(args.Timezone)
+	if args.Timezone == "" || err != nil {
+		loc = time.FixedZone("CST", 8*3600)
+	}
+	now := time.Now().In(loc)
+	return fmt.Sprintf("%s %s", now.Format("2006-01-02 15:04:05"), now.Weekday()), nil
+}
+
+func (t *TurnTools) rollDice(_ context.Context, args rollDiceArgs) (string, error) {
+	if args.Sides < 2 {
+		args.Sides = 6
+	}
+	if args.Count < 1 {
+		args.Count = 1
+	}
+	if args.Count > 10 {
+		args.Count = 10
+	}
+	points := make([]string, args.Count)
+	total := 0
+	for i := range points {
+		p := rand.Intn(args.Sides) + 1
+		total += p
+		points[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("点数：%s，总和：%d", strings.Join(points, "、"), total), nil
+}
+
+// knowledgeLookup 先检索角色知识库，没有命中时在角色设定中查找与问题字词重合最多的句子
+func (t *TurnTools) knowledgeLookup(ctx context.Context, args knowledgeLookupArgs) (string, error) {
+	query := []rune(strings.TrimSpace(args.Query))
+	if len(query) == 0 {
+		return "没有提供查询内容", nil
+	}
+	if t.knowledge != nil {
+		passages, err := t.knowledge.Search(ctx, t.role.ID, string(query), knowledgeTopK)
+		if err != nil {
+			return "", err
+		}
+		if len(passages) > 0 {
+			contents := make([]string, len(passages))
+			for i, p := range passages {
+				contents[i] = p.Content
+			}
+			return strings.Join(contents, "\n"), nil
+		}
+	}
+	best, bestScore := "", 0
+	for _, s := range strings.FieldsFunc(rolePrompt(t.role), func(r rune) bool {
+		return strings.ContainsRune("。！？!?\n", r)
+	}) {
+		score := 0
+		for i := 0; i+1 < len(query); i++ {
+			if strings.Contains(s, string(query[i:i+2])) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = strings.TrimSpace(s), score
+		}
+	}
+	if bestScore == 0 {
+		return "资料中没有找到相关内容", nil
+	}
+	return best, nil
+}
+
+func (t *TurnTools) endConversation(_ context.Context, args endConversationArgs) (string, error) {
+	t.ended.Store(true)
+	return "对话将在这句回复后结束，请说一句简短的告别语", nil
+}
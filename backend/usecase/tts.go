@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/usecase/utils"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// ttsMaxText 单次合成的最大字数
+	ttsMaxText = 500
+	// ttsDefaultVoice 未指定音色时使用
+	ttsDefaultVoice = "qiniu_zh_female_tmjxxy"
+	// ttsProvider 参与缓存 key 计算，切换服务商后不会命中旧的缓存
+	ttsProvider = "qiniu"
+)
+
+// TtsUsecase 非流式合成任意文本，结果按输入的哈希缓存在 OSS
+type TtsUsecase struct {
+	l           *log.Logger
+	config      *config.Config
+	voice       *VoiceUsecase
+	fileUsecase *FileUsecase
+	usage       *UsageUsecase
+	moderation  *ModerationUsecase
+}
+
+func NewTtsUsecase(l *log.Logger, c *config.Config, voice *VoiceUsecase, file *FileUsecase, usage *UsageUsecase, moderation *ModerationUsecase) *TtsUsecase {
+	return &TtsUsecase{
+		l:           l.WithModule("TtsUsecase"),
+		config:      c,
+		voice:       voice,
+		fileUsecase: file,
+		usage:       usage,
+		moderation:  moderation,
+	}
+}
+
+// Synthesize 返回合成结果和音频内容；OSS 中已有相同输入的结果时直接读取。
+// 额度用完时返回 *domain.QuotaError，实际合成的字数计入用户用量
+func (u *TtsUsecase) Synthesize(ctx context.Context, userID string, req domain.TtsReq) (domain.TtsResult, []byte, error) {
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		return domain.TtsResult{}, nil, errors.New("text is required")
+	}
+	if utf8.RuneCountInString(req.Text) > ttsMaxText {
+		return domain.TtsResult{}, nil, fmt.Errorf("text exceeds %d characters", ttsMaxText)
+	}
+	if req.Voice == "" {
+		req.Voice = ttsDefaultVoice
+	} else if _, err := u.voice.GetVoice(ctx, req.Voice); err != nil {
+		return domain.TtsResult{}, nil, err
+	}
+	if req.Speed == 0 {
+		req.Speed = 1.0
+	}
+	if req.Speed < 0.5 || req.Speed > 2.0 {
+		return domain.TtsResult{}, nil, errors.New("speed must be between 0.5 and 2.0")
+	}
+	switch req.Format {
+	case "":
+		req.Format = domain.TtsFormatMp3
+	case domain.TtsFormatMp3, domain.TtsFormatWav, domain.TtsFormatPcm:
+	default:
+		return domain.TtsResult{}, nil, fmt.Errorf("unsupported format: %s", req.Format)
+	}
+	if err := u.usage.Check(ctx, userID); err != nil {
+		return domain.TtsResult{}, nil, err
+	}
+	res := u.moderation.Check(ctx, userID, 0, domain.ModerationInput, req.Text)
+	if res.Action == domain.ModerationBlock {
+		return domain.TtsResult{}, nil, errors.New("text violates the content policy")
+	}
+	req.Text = res.Text
+
+	key := ttsCacheKey(req)
+	result := domain.TtsResult{Key: key, Url: u.fileUsecase.FileUrl(key), Format: req.Format}
+	exists, err := u.fileUsecase.FileExists(ctx, key)
+	if err != nil {
+		u.l.Warn("check tts cache failed", log.Error(err))
+	}
+	if exists {
+		audio, err := u.fileUsecase.ReadFile(ctx, key)
+		if err == nil {
+			result.Cached = true
+			return result, audio, nil
+		}
+		u.l.Warn("read tts cache failed", log.Error(err))
+	}
+
+	tts := utils.NewTtsStream(u.l, u.config)
+	var audio []byte
+	switch req.Format {
+	case domain.TtsFormatMp3:
+		audio, err = tts.TtsOnce(ctx, req.Text, req.Voice, domain.TtsFormatMp3, req.Speed)
+	case domain.TtsFormatWav, domain.TtsFormatPcm:
+		audio, err = tts.Synthesize(ctx, req.Text, req.Voice, req.Speed)
+		if err == nil && req.Format == domain.TtsFormatWav {
+			audio = utils.PCMToWav(audio, utils.TtsSampleRate)
+		}
+	}
+	if err != nil {
+		return domain.TtsResult{}, nil, err
+	}
+	u.usage.Record(ctx, domain.UsageRecord{UserID: userID, TtsChars: int64(utf8.RuneCountInString(req.Text))})
+	if _, err := u.fileUsecase.UploadFileWithWriter(ctx, key, bytes.NewReader(audio), int64(len(audio))); err != nil {
+		u.l.Warn("save tts cache failed", log.Error(err))
+	}
+	return result, audio, nil
+}
+
+// ttsCacheKey 由服务商、音色、语速、格式和文本计算
+func ttsCacheKey(req domain.TtsReq) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%.2f\n%s\n%s", ttsProvider, req.Voice, req.Speed, req.Format, req.Text)))
+	return fmt.Sprintf("tts/%s.%s", hex.EncodeToString(sum[:]), req.Format)
+}
+
+// TtsContentType 音频格式对应的 Content-Type
+func TtsContentType(format string) string {
+	switch format {
+	case domain.TtsFormatMp3:
+		return "audio/mpeg"
+	case domain.TtsFormatWav:
+		return "audio/wav"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,235 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/usecase/utils"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// ttsCacheMaxText 只缓存不超过这个字数的句子（开场白、拒绝语、常见短回复）
+	ttsCacheMaxText = 64
+	// ttsCacheMemoryBytes 内存 LRU 的容量
+	ttsCacheMemoryBytes = 64 << 20
+	// ttsCacheChunkSamples 命中时每次推送的采样数（200ms）
+	ttsCacheChunkSamples = utils.TtsSampleRate / 5
+)
+
+// TtsCache 按句缓存合成的 PCM：先查内存 LRU，再查 OSS，都没有时合成并回填
+type TtsCache struct {
+	l           *log.Logger
+	config      *config.Config
+	fileUsecase *FileUsecase
+	memory      *utils.LRU
+
+	memoryHits atomic.Int64
+	ossHits    atomic.Int64
+	misses     atomic.Int64
+	skipped    atomic.Int64
+}
+
+func NewTtsCache(l *log.Logger, c *config.Config, file *FileUsecase) *TtsCache {
+	return &TtsCache{
+		l:           l.WithModule("TtsCache"),
+		config:      c,
+		fileUsecase: file,
+		memory:      utils.NewLRU(ttsCacheMemoryBytes),
+	}
+}
+
+// Stream 与 TtsStream 相同的输入输出：逐句处理，命中缓存时立即推送，未命中的句子在本轮共用的一个合成连接上边合成边推送。
+// usage 不为空时累加实际调用合成接口的用量，命中缓存的句子不计
+func (c *TtsCache) Stream(ctx context.Context, textChunks <-chan string, voiceType string, speed float64, usage *utils.TtsUsage) (<-chan utils.PCMChunk, <-chan error) {
+	if speed <= 0 {
+		speed = 1.0
+	}
+	out := make(chan utils.PCMChunk, 16)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(errCh)
+		defer close(out)
+
+		seq := 0
+		emit := func(samples []int16) bool {
+			select {
+			case out <- utils.PCMChunk{Seq: seq, Samples: samples}:
+				seq++
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// 第一次未命中时才建立合成连接，整轮命中缓存时不连接
+		var (
+			missText chan string
+			stream   <-chan utils.PCMChunk
+			errs     <-chan error
+		)
+		defer func() {
+			if missText == nil {
+				return
+			}
+			close(missText)
+			// 排空上游，避免合成协程阻塞
+			go func() {
+				for range stream {
+				}
+			}()
+		}()
+
+		for text := range textChunks {
+			text = normalizeTtsText(text)
+			if text == "" {
+				continue
+			}
+			cacheable := utf8.RuneCountInString(text) <= ttsCacheMaxText
+			key := ttsSentenceKey(voiceType, speed, text)
+			if cacheable {
+				if pcm, ok := c.get(ctx, key); ok {
+					for len(pcm) > 0 {
+						n := min(len(pcm), ttsCacheChunkSamples*2)
+						if !emit(bytesToSamples(pcm[:n])) {
+							return
+						}
+						pcm = pcm[n:]
+					}
+					continue
+				}
+				c.misses.Add(1)
+			} else {
+				c.skipped.Add(1)
+			}
+
+			if missText == nil {
+				missText = make(chan string, 1)
+				stream, errs = utils.NewTtsStream(c.l, c.config).WithSpeed(speed).WithUsage(usage).WithSentenceEnds().TtsStream(ctx, missText, voiceType)
+			}
+			// 上一句已经结束，说明写入协程已取走上一句，这里不会阻塞
+			missText <- text
+			var buf bytes.Buffer
+			ended := false
+			for chunk := range stream {
+				if chunk.End {
+					ended = true
+					break
+				}
+				if !emit(chunk.Samples) {
+					return
+				}
+				if cacheable {
+					buf.Write(samplesToBytes(chunk.Samples))
+				}
+			}
+			if !ended {
+				// 连接在这一句结束前断开
+				if err := <-errs; err != nil {
+					errCh <- err
+				} else if ctx.Err() == nil {
+					errCh <- fmt.Errorf("tts stream closed before sentence finished")
+				}
+				return
+			}
+			if cacheable && buf.Len() > 0 && ctx.Err() == nil {
+				c.put(key, buf.Bytes())
+			}
+		}
+		if missText != nil {
+			close(missText)
+			missText = nil
+			for range stream {
+			}
+			if err := <-errs; err != nil {
+				errCh <- err
+			}
+		}
+	}()
+
+	return out, errCh
+}
+
+func (c *TtsCache) get(ctx context.Context, key string) ([]byte, bool) {
+	if pcm, ok := c.memory.Get(key); ok {
+		c.memoryHits.Add(1)
+		return pcm, true
+	}
+	exists, err := c.fileUsecase.FileExists(ctx, key)
+	if err != nil {
+		c.l.Warn("check tts cache failed", log.Error(err))
+		return nil, false
+	}
+	if !exists {
+		return nil, false
+	}
+	pcm, err := c.fileUsecase.ReadFile(ctx, key)
+	if err != nil || len(pcm) == 0 {
+		return nil, false
+	}
+	c.ossHits.Add(1)
+	c.memory.Add(key, pcm)
+	return pcm, true
+}
+
+// put 写入内存，并异步保存到 OSS
+func (c *TtsCache) put(key string, pcm []byte) {
+	c.memory.Add(key, pcm)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := c.fileUsecase.UploadFileWithWriter(ctx, key, bytes.NewReader(pcm), int64(len(pcm))); err != nil {
+			c.l.Warn("save tts cache failed", log.Error(err))
+		}
+	}()
+}
+
+func (c *TtsCache) Stats() domain.TtsCacheStats {
+	s := domain.TtsCacheStats{
+		MemoryHits: c.memoryHits.Load(),
+		OssHits:    c.ossHits.Load(),
+		Misses:     c.misses.Load(),
+		Skipped:    c.skipped.Load(),
+	}
+	if total := s.MemoryHits + s.OssHits + s.Misses; total > 0 {
+		s.HitRate = float64(s.MemoryHits+s.OssHits) / float64(total)
+	}
+	s.Entries, s.Bytes = c.memory.Len()
+	return s
+}
+
+// normalizeTtsText 去掉首尾和重复的空白，使相同的句子得到相同的 key
+func normalizeTtsText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func ttsSentenceKey(voiceType string, speed float64, text string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%.2f\n%s", ttsProvider, voiceType, speed, text)))
+	return fmt.Sprintf("tts/pcm/%s.pcm", hex.EncodeToString(sum[:]))
+}
+
+func samplesToBytes(samples []int16) []byte {
+	b := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		b[2*i] = byte(s)
+		b[2*i+1] = byte(s >> 8)
+	}
+	return b
+}
+
+func bytesToSamples(b []byte) []int16 {
+	samples := make([]int16, len(b)/2)
+	for i := range samples {
+		samples[i] = int16(b[2*i]) | int16(b[2*i+1])<<8
+	}
+	return samples
+}
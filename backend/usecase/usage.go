@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/repo"
+	"time"
+)
+
+// UsageUsecase 记录每轮对话的用量，并按自然日、自然月检查额度
+type UsageUsecase struct {
+	l         *log.Logger
+	config    *config.Config
+	usageRepo *repo.UsageRepo
+}
+
+func NewUsageUsecase(l *log.Logger, c *config.Config, usageRepo *repo.UsageRepo) *UsageUsecase {
+	return &UsageUsecase{
+		l:         l.WithModule("UsageUsecase"),
+		config:    c,
+		usageRepo: usageRepo,
+	}
+}
+
+// Record 保存一轮对话的用量，失败只记录日志，不影响对话
+func (u *UsageUsecase) Record(ctx context.Context, rec domain.UsageRecord) {
+	if err := u.usageRepo.CreateUsage(ctx, rec); err != nil {
+		u.l.Error("record usage failed", log.String("user_id", rec.UserID), log.Error(err))
+	}
+}
+
+// Get 返回用户本日和本月的用量及额度
+func (u *UsageUsecase) Get(ctx context.Context, userID string) (domain.UserUsage, error) {
+	now := time.Now()
+	q := u.config.Quota
+	daily := domain.UsagePeriod{
+		Start: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()),
+		Limit: domain.UsageLimit{Tokens: q.DailyTokens, AsrSeconds: q.DailyAsrSeconds, TtsChars: q.DailyTtsChars},
+	}
+	monthly := domain.UsagePeriod{
+		Start: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()),
+		Limit: domain.UsageLimit{Tokens: q.MonthlyTokens, AsrSeconds: q.MonthlyAsrSeconds, TtsChars: q.MonthlyTtsChars},
+	}
+	var err error
+	if daily.Used, err = u.usageRepo.SumUsage(ctx, userID, daily.Start); err != nil {
+		return domain.UserUsage{}, err
+	}
+	if monthly.Used, err = u.usageRepo.SumUsage(ctx, userID, monthly.Start); err != nil {
+		return domain.UserUsage{}, err
+	}
+	return domain.UserUsage{Daily: daily, Monthly: monthly}, nil
+}
+
+// Check 额度用完时返回 *domain.QuotaError。查询失败时放行，避免数据库抖动打断对话
+func (u *UsageUsecase) Check(ctx context.Context, userID string) error {
+	usage, err := u.Get(ctx, userID)
+	if err != nil {
+		u.l.Warn("check quota failed", log.String("user_id", userID), log.Error(err))
+		return nil
+	}
+	return usage.Exceeded()
+}
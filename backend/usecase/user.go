@@ -2,34 +2,335 @@ package usecase
 
 import (
 	"context"
+	"crypto/sha256"
 	"demo/config"
 	"demo/domain"
 	"demo/pkg/log"
+	"demo/pkg/token"
 	"demo/repo"
+	"demo/usecase/utils"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 登录失败限制：同一账号或同一 IP 在窗口内失败次数达到上限后锁定
+const (
+	loginAccountFailures = 5
+	loginIPFailures      = 20
+	loginFailureWindow   = 15 * time.Minute
+	loginLockout         = 15 * time.Minute
 )
 
+// 同一 IP 在窗口内创建游客的次数上限，达到后锁定
+const (
+	guestPerIP   = 10
+	guestWindow  = time.Hour
+	guestLockout = time.Hour
+)
+
+// ErrLoginLocked 登录失败次数过多，暂时锁定
+var ErrLoginLocked = errors.New("too many failed login attempts")
+
+// ErrGuestLimited 同一 IP 创建游客过于频繁
+var ErrGuestLimited = errors.New("too many guest sessions from this ip")
+
 type UserUsecase struct {
-	l        *log.Logger
-	userrepo *repo.UserRepo
-	config   *config.Config
+	l           *log.Logger
+	userrepo    *repo.UserRepo
+	tokenRepo   *repo.TokenRepo
+	keys        *token.KeySet
+	fileUsecase *FileUsecase
+	config      *config.Config
+
+	accountThrottle *utils.Throttle
+	ipThrottle      *utils.Throttle
+	guestThrottle   *utils.Throttle
+	purging         sync.Mutex
 }
 
-func NewUserUsecase(l *log.Logger, userrepo *repo.UserRepo, config *config.Config) *UserUsecase {
+func NewUserUsecase(l *log.Logger, userrepo *repo.UserRepo, tokenRepo *repo.TokenRepo, keys *token.KeySet, file *FileUsecase, config *config.Config) *UserUsecase {
 	return &UserUsecase{
-		l:        l.WithModule("UserUsecase"),
-		userrepo: userrepo,
-		config:   config,
+		l:           l.WithModule("UserUsecase"),
+		userrepo:    userrepo,
+		tokenRepo:   tokenRepo,
+		keys:        keys,
+		fileUsecase: file,
+		config:      config,
+
+		accountThrottle: utils.NewThrottle(loginAccountFailures, loginFailureWindow, loginLockout),
+		ipThrottle:      utils.NewThrottle(loginIPFailures, loginFailureWindow, loginLockout),
+		guestThrottle:   utils.NewThrottle(guestPerIP, guestWindow, guestLockout),
 	}
 }
 
 func (u *UserUsecase) Register(ctx context.Context, req *domain.CreateUserReq) error {
-	return u.userrepo.CreateUser(ctx, domain.User{Name: req.Name, Password: req.Password})
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	_, err := u.userrepo.CreateUser(ctx, domain.User{Name: req.Name, Password: req.Password})
+	return err
+}
+
+// Login 校验密码，签发 access token 和新的 refresh token；账号或 IP 失败次数过多时返回 ErrLoginLocked
+func (u *UserUsecase) Login(ctx context.Context, req *domain.LoginReq, ip string) (*domain.LoginResp, error) {
+	for _, t := range []struct {
+		throttle *utils.Throttle
+		key      string
+	}{{u.accountThrottle, req.Name}, {u.ipThrottle, ip}} {
+		if locked, left := t.throttle.Locked(t.key); locked {
+			return nil, fmt.Errorf("%w, try again in %d minutes", ErrLoginLocked, int(left.Minutes())+1)
+		}
+	}
+	user, err := u.userrepo.VerifyPassword(ctx, req.Name, req.Password)
+	if err != nil {
+		u.accountThrottle.Fail(req.Name)
+		u.ipThrottle.Fail(ip)
+		u.l.Warn("login failed", log.String("name", req.Name), log.String("ip", ip))
+		return nil, errors.New("invalid name or password")
+	}
+	u.accountThrottle.Reset(req.Name)
+	return u.newSession(ctx, user, time.Now().Add(time.Duration(u.config.Jwt.RefreshTTL)*time.Second))
+}
+
+// Guest 创建游客并登录，游客不需要用户名密码，可以直接开始语音对话，次数有限，过期后自动删除。
+// 同一 IP 创建过于频繁时返回 ErrGuestLimited
+func (u *UserUsecase) Guest(ctx context.Context, ip string) (*domain.LoginResp, error) {
+	if locked, left := u.guestThrottle.Locked(ip); locked {
+		return nil, fmt.Errorf("%w, try again in %d minutes", ErrGuestLimited, int(left.Minutes())+1)
+	}
+	u.guestThrottle.Fail(ip)
+	user, err := u.userrepo.CreateUser(ctx, domain.User{
+		Name:     "guest_" + strings.ReplaceAll(uuid.NewString(), "-", "")[:16],
+		Password: token.NewRefreshToken(),
+		Role:     domain.AccountGuest,
+	})
+	if err != nil {
+		return nil, err
+	}
+	go u.purgeGuests()
+	return u.newSession(ctx, user, u.guestExpiresAt(user))
 }
 
-func (u *UserUsecase) Login(ctx context.Context, req *domain.LoginReq) (*domain.LoginResp, error) {
-	token, err := u.userrepo.VertifyUserPasswordAndGenerateToken(ctx, domain.User{Name: req.Name, Password: req.Password})
+// ClaimGuest 游客注册为正式用户，对话等数据转到新账号下，游客的 token 随之失效
+func (u *UserUsecase) ClaimGuest(ctx context.Context, guestID string, req *domain.CreateUserReq) (*domain.LoginResp, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	user, err := u.userrepo.ClaimGuest(ctx, guestID, domain.User{Name: req.Name, Password: req.Password})
 	if err != nil {
 		return nil, err
 	}
-	return &domain.LoginResp{Token: token}, nil
+	u.l.Info("guest claimed", log.String("guest_id", guestID), log.String("user_id", user.ID))
+	return u.newSession(ctx, user, time.Now().Add(time.Duration(u.config.Jwt.RefreshTTL)*time.Second))
+}
+
+func (u *UserUsecase) guestExpiresAt(user domain.User) time.Time {
+	return time.Unix(user.CreateTime, 0).Add(time.Duration(u.config.Guest.TTL) * time.Second)
+}
+
+// purgeGuests 删除过期的游客，创建游客时顺带执行，同一时间只跑一个
+func (u *UserUsecase) purgeGuests() {
+	if !u.purging.TryLock() {
+		return
+	}
+	defer u.purging.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	before := time.Now().Add(-time.Duration(u.config.Guest.TTL) * time.Second).Unix()
+	ids, err := u.userrepo.ListExpiredGuests(ctx, before, 50)
+	if err != nil {
+		u.l.Warn("list expired guests failed", log.Error(err))
+		return
+	}
+	for _, id := range ids {
+		fileKeys, err := u.userrepo.DeleteUser(ctx, id)
+		if err != nil {
+			u.l.Warn("delete expired guest failed", log.String("user_id", id), log.Error(err))
+			continue
+		}
+		for _, key := range fileKeys {
+			if err := u.fileUsecase.RemoveFile(ctx, key); err != nil {
+				u.l.Warn("remove file failed", log.String("key", key), log.Error(err))
+			}
+		}
+	}
+}
+
+// newSession 新建一个 refresh token family 并签发第一对 token
+func (u *UserUsecase) newSession(ctx context.Context, user domain.User, expiresAt time.Time) (*domain.LoginResp, error) {
+	refresh := token.NewRefreshToken()
+	if err := u.tokenRepo.CreateRefreshToken(ctx, domain.RefreshToken{
+		Hash:      hashToken(refresh),
+		UserID:    user.ID,
+		FamilyID:  uuid.NewString(),
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return nil, err
+	}
+	return u.loginResp(user, refresh)
+}
+
+// Refresh 用 refresh token 换新的一对 token，旧的 refresh token 随即失效
+func (u *UserUsecase) Refresh(ctx context.Context, req *domain.RefreshReq) (*domain.LoginResp, error) {
+	if req.RefreshToken == "" {
+		return nil, errors.New("refresh_token is required")
+	}
+	refresh := token.NewRefreshToken()
+	next, err := u.tokenRepo.RotateRefreshToken(ctx, hashToken(req.RefreshToken), domain.RefreshToken{
+		Hash:      hashToken(refresh),
+		ExpiresAt: time.Now().Add(time.Duration(u.config.Jwt.RefreshTTL) * time.Second),
+	})
+	if errors.Is(err, repo.ErrRefreshTokenReused) {
+		u.l.Warn("refresh token reused, session revoked", log.String("user_id", next.UserID))
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 每次刷新都重新读取账号角色，角色变更在 access token 过期后生效
+	user, err := u.userrepo.GetUserByID(ctx, next.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == domain.AccountGuest && time.Now().After(u.guestExpiresAt(user)) {
+		return nil, errors.New("guest session expired")
+	}
+	return u.loginResp(user, refresh)
+}
+
+// Logout 作废当前登录的 refresh token；access token 在过期前仍然有效
+func (u *UserUsecase) Logout(ctx context.Context, userID string, req *domain.RefreshReq) error {
+	return u.tokenRepo.RevokeByHash(ctx, userID, hashToken(req.RefreshToken))
+}
+
+// LogoutAll 作废用户在所有设备上的 refresh token
+func (u *UserUsecase) LogoutAll(ctx context.Context, userID string) error {
+	return u.tokenRepo.RevokeUser(ctx, userID)
+}
+
+func (u *UserUsecase) GetProfile(ctx context.Context, userID string) (domain.UserProfile, error) {
+	user, err := u.userrepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return domain.UserProfile{}, err
+	}
+	profile := user.Profile()
+	if profile.Avatar != "" {
+		profile.AvatarUrl = u.fileUsecase.FileUrl(profile.Avatar)
+	}
+	return profile, nil
+}
+
+func (u *UserUsecase) UpdateProfile(ctx context.Context, userID string, req domain.UpdateProfileReq) (domain.UserProfile, error) {
+	updates, err := req.Validate(userID)
+	if err != nil {
+		return domain.UserProfile{}, err
+	}
+	if len(updates) > 0 {
+		if err := u.userrepo.UpdateProfile(ctx, userID, updates); err != nil {
+			return domain.UserProfile{}, err
+		}
+	}
+	return u.GetProfile(ctx, userID)
+}
+
+// checkPassword 已登录用户的密码校验，与登录共用按账号的失败计数，失败次数过多时返回 ErrLoginLocked
+func (u *UserUsecase) checkPassword(ctx context.Context, name, password string) error {
+	if locked, left := u.accountThrottle.Locked(name); locked {
+		return fmt.Errorf("%w, try again in %d minutes", ErrLoginLocked, int(left.Minutes())+1)
+	}
+	if _, err := u.userrepo.VerifyPassword(ctx, name, password); err != nil {
+		u.accountThrottle.Fail(name)
+		return err
+	}
+	u.accountThrottle.Reset(name)
+	return nil
+}
+
+// ChangePassword 校验旧密码后修改，并让其他设备重新登录
+func (u *UserUsecase) ChangePassword(ctx context.Context, userID string, req domain.ChangePasswordReq) error {
+	if err := domain.ValidatePassword(req.NewPassword); err != nil {
+		return err
+	}
+	user, err := u.userrepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := u.checkPassword(ctx, user.Name, req.OldPassword); err != nil {
+		return err
+	}
+	if err := u.userrepo.UpDataPassword(ctx, user, req.NewPassword); err != nil {
+		return err
+	}
+	return u.tokenRepo.RevokeUser(ctx, userID)
+}
+
+// DeleteAccount 校验密码后删除账号及其全部数据，OSS 上的录音和头像在后台删除
+func (u *UserUsecase) DeleteAccount(ctx context.Context, userID string, req domain.DeleteAccountReq) error {
+	user, err := u.userrepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := u.checkPassword(ctx, user.Name, req.Password); err != nil {
+		return err
+	}
+	fileKeys, err := u.userrepo.DeleteUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	u.l.Info("account deleted", log.String("user_id", userID), log.Int("files", len(fileKeys)))
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		for _, key := range fileKeys {
+			if err := u.fileUsecase.RemoveFile(ctx, key); err != nil {
+				u.l.Warn("remove file failed", log.String("key", key), log.Error(err))
+			}
+		}
+		if err := u.fileUsecase.RemovePrefix(ctx, domain.AvatarPrefix(userID)); err != nil {
+			u.l.Warn("remove avatars failed", log.String("user_id", userID), log.Error(err))
+		}
+	}()
+	return nil
+}
+
+// SetRole 管理员修改其他用户的账号角色，不能修改自己的，避免误操作后没有管理员
+func (u *UserUsecase) SetRole(ctx context.Context, operatorID, userID string, req domain.UpdateAccountRoleReq) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	if operatorID == userID {
+		return errors.New("cannot change your own role")
+	}
+	if err := u.userrepo.UpdateRole(ctx, userID, req.Role); err != nil {
+		return err
+	}
+	u.l.Info("account role changed", log.String("operator", operatorID), log.String("user_id", userID), log.String("role", req.Role))
+	return nil
+}
+
+// accountRole 返回用户的账号角色，第一个管理员用 createadmin 命令创建
+func (u *UserUsecase) accountRole(user domain.User) string {
+	if !domain.ValidAccountRole(user.Role) {
+		return domain.AccountUser
+	}
+	return user.Role
+}
+
+func (u *UserUsecase) loginResp(user domain.User, refresh string) (*domain.LoginResp, error) {
+	role := u.accountRole(user)
+	access, exp, err := u.keys.AccessToken(user.ID, role, domain.Permissions(role))
+	if err != nil {
+		return nil, err
+	}
+	return &domain.LoginResp{Token: access, ExpiresAt: exp, RefreshToken: refresh}, nil
+}
+
+func hashToken(t string) string {
+	sum := sha256.Sum256([]byte(t))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,173 @@
+package usecase
+
+import (
+	"context"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/repo"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// avatarMaxFileSize 头像大小上限
+const avatarMaxFileSize = 2 << 20
+
+// UserRoleUsecase 用户自建角色：私有角色只有自己可用，公开角色需审核通过后才出现在列表中
+type UserRoleUsecase struct {
+	l           *log.Logger
+	config      *config.Config
+	roleRepo    *repo.RoleRepo
+	roles       RoleUsecase
+	voice       *VoiceUsecase
+	moderation  *ModerationUsecase
+	fileUsecase *FileUsecase
+}
+
+func NewUserRoleUsecase(l *log.Logger, c *config.Config, roleRepo *repo.RoleRepo, roles RoleUsecase, voice *VoiceUsecase, moderation *ModerationUsecase, file *FileUsecase) *UserRoleUsecase {
+	return &UserRoleUsecase{
+		l:           l.WithModule("UserRoleUsecase"),
+		config:      c,
+		roleRepo:    roleRepo,
+		roles:       roles,
+		voice:       voice,
+		moderation:  moderation,
+		fileUsecase: file,
+	}
+}
+
+func (u *UserRoleUsecase) ListRoles(ctx context.Context, userID string) (domain.AdminRoleList, error) {
+	roles, err := u.roleRepo.ListByOwner(ctx, userID)
+	if err != nil {
+		return domain.AdminRoleList{}, err
+	}
+	return domain.AdminRoleList{Roles: roles}, nil
+}
+
+func (u *UserRoleUsecase) CreateRole(ctx context.Context, userID string, req domain.UserRoleReq) (domain.Role, error) {
+	n, err := u.roleRepo.CountByOwner(ctx, userID)
+	if err != nil {
+		return domain.Role{}, err
+	}
+	if n >= int64(u.config.UserRole.Quota) {
+		return domain.Role{}, fmt.Errorf("role quota exceeded: at most %d roles per user", u.config.UserRole.Quota)
+	}
+	role := domain.Role{OwnerID: userID, Status: domain.RolePublished, Version: 1}
+	req.Apply(&role)
+	if err := u.check(ctx, userID, &role, domain.Role{}); err != nil {
+		return domain.Role{}, err
+	}
+	if err := u.roleRepo.CreateRole(ctx, &role, newRoleVersion(role, domain.Role{}, userID, "")); err != nil {
+		return domain.Role{}, err
+	}
+	return role, nil
+}
+
+func (u *UserRoleUsecase) UpdateRole(ctx context.Context, userID string, id int, req domain.UserRoleReq) (domain.Role, error) {
+	old, err := u.ownedRole(ctx, userID, id)
+	if err != nil {
+		return domain.Role{}, err
+	}
+	role := old
+	req.Apply(&role)
+	if err := u.check(ctx, userID, &role, old); err != nil {
+		return domain.Role{}, err
+	}
+	versions, err := editVersions(ctx, u.roleRepo, &role, old, userID, "")
+	if err != nil {
+		return domain.Role{}, err
+	}
+	if err := u.roleRepo.UpdateRole(ctx, role, versions...); err != nil {
+		return domain.Role{}, err
+	}
+	return role, nil
+}
+
+func (u *UserRoleUsecase) DeleteRole(ctx context.Context, userID string, id int) error {
+	if _, err := u.ownedRole(ctx, userID, id); err != nil {
+		return err
+	}
+	return u.roles.DeleteRole(ctx, id)
+}
+
+// UploadAvatar 上传头像图片，返回可直接作为 image_url 保存的访问地址
+func (u *UserRoleUsecase) UploadAvatar(ctx context.Context, userID string, file *multipart.FileHeader) (string, error) {
+	if file.Size > avatarMaxFileSize {
+		return "", fmt.Errorf("file too large: %d bytes", file.Size)
+	}
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	switch http.DetectContentType(head[:n]) {
+	case "image/jpeg", "image/png", "image/webp", "image/gif":
+	default:
+		return "", errors.New("avatar must be a jpeg, png, webp or gif image")
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	name := domain.AvatarPrefix(userID) + uuid.New().String() + strings.ToLower(filepath.Ext(file.Filename))
+	key, err := u.fileUsecase.UploadFileWithWriter(ctx, name, f, file.Size)
+	if err != nil {
+		return "", err
+	}
+	return u.fileUsecase.FileUrl(key), nil
+}
+
+func (u *UserRoleUsecase) ownedRole(ctx context.Context, userID string, id int) (domain.Role, error) {
+	role, err := u.roleRepo.GetroleById(ctx, id)
+	if err != nil {
+		return domain.Role{}, err
+	}
+	if role.OwnerID == "" || role.OwnerID != userID {
+		return domain.Role{}, fmt.Errorf("role %d does not belong to user %s", id, userID)
+	}
+	return role, nil
+}
+
+// check 校验角色内容、音色并做内容审核；公开角色的内容有变化时重新进入待审核
+func (u *UserRoleUsecase) check(ctx context.Context, userID string, role *domain.Role, old domain.Role) error {
+	switch role.Visibility {
+	case "":
+		role.Visibility = domain.RolePrivate
+	case domain.RolePrivate, domain.RolePublic:
+	default:
+		return fmt.Errorf("unknown visibility: %s", role.Visibility)
+	}
+	if err := role.Validate(); err != nil {
+		return err
+	}
+	// 音色必须在服务商的音色列表中，列表有缓存
+	if role.Voice != "" && role.Voice != old.Voice {
+		voice, err := u.voice.GetVoice(ctx, role.Voice)
+		if err != nil {
+			return err
+		}
+		role.VoiceSampleUrl = voice.SampleUrl
+	}
+	text := userRoleText(*role)
+	if res := u.moderation.Check(ctx, userID, role.ID, domain.ModerationInput, text); res.Action == domain.ModerationBlock {
+		return errors.New("role content violates the content policy")
+	}
+	if role.Visibility == domain.RolePublic && (old.Visibility != domain.RolePublic || text != userRoleText(old)) {
+		role.ReviewStatus = domain.ReviewPending
+		role.ReviewNote = ""
+	}
+	return nil
+}
+
+// userRoleText 需要审核的文本
+func userRoleText(role domain.Role) string {
+	return strings.Join([]string{role.Name, role.Description, strings.Join(role.Tags, " "), roleVersionText(role)}, "\n")
+}
@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Tokenize 把文本切成检索用的词：汉字取相邻二字组（单字时取单字），字母数字按词切分并转小写
+func Tokenize(s string) []string {
+	var tokens []string
+	var han, word []rune
+	flushHan := func() {
+		if len(han) == 1 {
+			tokens = append(tokens, string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			tokens = append(tokens, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+		}
+		word = word[:0]
+	}
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushHan()
+			flushWord()
+		}
+	}
+	flushHan()
+	flushWord()
+	return tokens
+}
+
+// ChunkText 按句子把长文本切成不超过 size 个字的段落，相邻段落重叠一句以保留上下文
+func ChunkText(text string, size int) []string {
+	var sentences []string
+	var cur []rune
+	for _, r := range text {
+		cur = append(cur, r)
+		if strings.ContainsRune("。！？!?；;\n", r) {
+			if s := strings.TrimSpace(string(cur)); s != "" {
+				sentences = append(sentences, s)
+			}
+			cur = cur[:0]
+		}
+	}
+	if s := strings.TrimSpace(string(cur)); s != "" {
+		sentences = append(sentences, s)
+	}
+
+	// 超过 size 的句子（例如没有标点的长文本）按 size 硬切
+	var pieces []string
+	for _, s := range sentences {
+		rs := []rune(s)
+		for len(rs) > size {
+			pieces = append(pieces, string(rs[:size]))
+			rs = rs[size:]
+		}
+		pieces = append(pieces, string(rs))
+	}
+	sentences = pieces
+
+	var chunks []string
+	var buf []string
+	length := 0
+	for _, s := range sentences {
+		n := len([]rune(s))
+		if length+n > size && len(buf) > 0 {
+			chunks = append(chunks, strings.Join(buf, ""))
+			last := buf[len(buf)-1]
+			buf, length = nil, 0
+			if len([]rune(last))+n <= size {
+				buf, length = []string{last}, len([]rune(last))
+			}
+		}
+		buf = append(buf, s)
+		length += n
+	}
+	if len(buf) > 0 {
+		chunks = append(chunks, strings.Join(buf, ""))
+	}
+	return chunks
+}
+
+// BM25 本地的 BM25 倒排检索
+type BM25 struct {
+	k1, b  float64
+	tf     []map[string]int
+	docLen []int
+	avgLen float64
+	df     map[string]int
+}
+
+// BM25Hit 检索结果，Index 为建索引时文档的下标
+type BM25Hit struct {
+	Index int
+	Score float64
+}
+
+func NewBM25(docs []string) *BM25 {
+	idx := &BM25{k1: 1.5, b: 0.75, df: make(map[string]int)}
+	total := 0
+	for _, d := range docs {
+		tf := make(map[string]int)
+		tokens := Tokenize(d)
+		for _, t := range tokens {
+			tf[t]++
+		}
+		for t := range tf {
+			idx.df[t]++
+		}
+		idx.tf = append(idx.tf, tf)
+		idx.docLen = append(idx.docLen, len(tokens))
+		total += len(tokens)
+	}
+	if len(docs) > 0 {
+		idx.avgLen = float64(total) / float64(len(docs))
+	}
+	return idx
+}
+
+// Search 返回得分最高的 k 个文档（只返回得分大于 0 的）
+func (idx *BM25) Search(query string, k int) []BM25Hit {
+	n := float64(len(idx.tf))
+	terms := make(map[string]struct{})
+	for _, t := range Tokenize(query) {
+		terms[t] = struct{}{}
+	}
+	var hits []BM25Hit
+	for i, tf := range idx.tf {
+		score := 0.0
+		for t := range terms {
+			f := float64(tf[t])
+			if f == 0 {
+				continue
+			}
+			df := float64(idx.df[t])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - idx.b + idx.b*float64(idx.docLen[i])/idx.avgLen
+			score += idf * f * (idx.k1 + 1) / (f + idx.k1*norm)
+		}
+		if score > 0 {
+			hits = append(hits, BM25Hit{Index: i, Score: score})
+		}
+	}
+	sort.Slice(hits, func(a, b int) bool { return hits[a].Score > hits[b].Score })
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
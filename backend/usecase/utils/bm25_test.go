@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := strings.Join(Tokenize("学而时习之，Hello World 2024"), "|")
+	if got != "学而|而时|时习|习之|hello|world|2024" {
+		t.Errorf("tokens = %s", got)
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	text := strings.Repeat("子曰学而时习之不亦说乎。", 10)
+	chunks := ChunkText(text, 40)
+	if len(chunks) < 3 {
+		t.Fatalf("chunks = %d", len(chunks))
+	}
+	for _, c := range chunks {
+		if n := len([]rune(c)); n > 40 {
+			t.Errorf("chunk too long: %d", n)
+		}
+	}
+	// 超长的句子被硬切
+	long := ChunkText("一二三四五六七八九十甲乙", 5)
+	if strings.Join(long, "") != "一二三四五六七八九十甲乙" {
+		t.Errorf("long sentence chunks = %q", long)
+	}
+	for _, c := range long {
+		if n := len([]rune(c)); n > 5 {
+			t.Errorf("chunk too long: %q", c)
+		}
+	}
+	if chunks := ChunkText("  \n ", 40); len(chunks) != 0 {
+		t.Errorf("empty text produced %d chunks", len(chunks))
+	}
+}
+
+func TestBM25Search(t *testing.T) {
+	idx := NewBM25([]string{
+		"子曰：学而时习之，不亦说乎？有朋自远方来，不亦乐乎？",
+		"子曰：温故而知新，可以为师矣。",
+		"孔子生于鲁国陬邑，名丘，字仲尼。",
+	})
+	hits := idx.Search("孔子的字是什么", 2)
+	if len(hits) == 0 || hits[0].Index != 2 {
+		t.Fatalf("hits = %+v", hits)
+	}
+	hits = idx.Search("温故知新", 1)
+	if len(hits) != 1 || hits[0].Index != 1 {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if hits := idx.Search("相对论", 3); len(hits) != 0 {
+		t.Errorf("unrelated query hits = %+v", hits)
+	}
+}
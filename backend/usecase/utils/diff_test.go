@@ -0,0 +1,19 @@
+package utils
+
+import "testing"
+
+func TestLineDiff(t *testing.T) {
+	cases := []struct {
+		a, b, want string
+	}{
+		{"", "a\nb", "+ a\n+ b\n"},
+		{"a\nb", "", "- a\n- b\n"},
+		{"a\nb\nc", "a\nc", "  a\n- b\n  c\n"},
+		{"a\nb\nc", "a\nx\nc\nd", "  a\n- b\n+ x\n  c\n+ d\n"},
+	}
+	for _, c := range cases {
+		if got := LineDiff(c.a, c.b); got != c.want {
+			t.Errorf("LineDiff(%q, %q) = %q, want %q", c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU 按总字节数限制容量的 LRU 缓存，并发安全
+type LRU struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+func NewLRU(maxBytes int) *LRU {
+	return &LRU{maxBytes: maxBytes, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+// Add 写入或更新，超过容量时淘汰最久未使用的项；单个值超过容量时不缓存
+func (c *LRU) Add(key string, value []byte) {
+	if len(value) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.bytes += len(value) - len(e.Value.(*lruEntry).value)
+		e.Value.(*lruEntry).value = value
+		c.ll.MoveToFront(e)
+	} else {
+		c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value})
+		c.bytes += len(value)
+	}
+	for c.bytes > c.maxBytes {
+		e := c.ll.Back()
+		entry := e.Value.(*lruEntry)
+		c.ll.Remove(e)
+		delete(c.items, entry.key)
+		c.bytes -= len(entry.value)
+	}
+}
+
+// Len 返回缓存的项数和总字节数
+func (c *LRU) Len() (int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len(), c.bytes
+}
@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func TestLRU(t *testing.T) {
+	c := NewLRU(10)
+	c.Add("a", []byte("aaaa"))
+	c.Add("b", []byte("bbbb"))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a should be cached")
+	}
+	// 超出容量时淘汰最久未使用的 b
+	c.Add("c", []byte("cccc"))
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || string(v) != "aaaa" {
+		t.Errorf("a = %q, %v", v, ok)
+	}
+	c.Add("big", make([]byte, 11))
+	if _, ok := c.Get("big"); ok {
+		t.Error("value larger than the cache should not be stored")
+	}
+	if n, size := c.Len(); n != 2 || size != 8 {
+		t.Errorf("len = %d, bytes = %d", n, size)
+	}
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/braheezy/shine-mp3/pkg/mp3"
+)
+
+// PCMToMp3 把单声道 16 位小端 PCM 编码成 128kbps 的 mp3
+func PCMToMp3(pcm []byte, sampleRate int) ([]byte, error) {
+	if mp3.CheckConfig(sampleRate, 128) < 0 {
+		return nil, fmt.Errorf("unsupported mp3 sample rate: %d", sampleRate)
+	}
+	enc := mp3.NewEncoder(sampleRate, 1)
+	// 编码器每次读取一帧的采样，不足一帧时会越界读取，所以按帧送入，最后一帧补静音
+	frame := make([]int16, int(enc.Mpeg.GranulesPerFrame)*mp3.GRANULE_SIZE)
+	samples := bytesToInt16(pcm)
+	var out bytes.Buffer
+	for off := 0; off < len(samples); off += len(frame) {
+		n := copy(frame, samples[off:])
+		clear(frame[n:])
+		if err := enc.Write(&out, frame); err != nil {
+			return nil, err
+		}
+	}
+	return out.Bytes(), nil
+}
+
+func bytesToInt16(b []byte) []int16 {
+	samples := make([]int16, len(b)/2)
+	for i := range samples {
+		samples[i] = int16(b[2*i]) | int16(b[2*i+1])<<8
+	}
+	return samples
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestPCMToMp3(t *testing.T) {
+	// 1 秒 440Hz 正弦波，长度不是整帧
+	pcm := make([]byte, (TtsSampleRate+100)*2)
+	for i := 0; i < len(pcm)/2; i++ {
+		v := int16(8000 * math.Sin(2*math.Pi*440*float64(i)/TtsSampleRate))
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(v))
+	}
+	mp3, err := PCMToMp3(pcm, TtsSampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mp3) < 2 || mp3[0] != 0xFF || mp3[1]&0xE0 != 0xE0 {
+		t.Fatalf("output does not start with an mp3 frame header")
+	}
+	if _, err := PCMToMp3(pcm, 12345); err == nil {
+		t.Error("unsupported sample rate should fail")
+	}
+}
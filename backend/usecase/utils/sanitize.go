@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"context"
+	"demo/domain"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	mdImageRe   = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	mdLinkRe    = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdBoldRe    = regexp.MustCompile(`(\*\*|__)(.+?)(\*\*|__)`)
+	mdHeadingRe = regexp.MustCompile(`(?m)^\s*#{1,6}\s*`)
+	mdListRe    = regexp.MustCompile(`(?m)^\s*([-*+•]|\d+[.)、])\s+`)
+	mdQuoteRe   = regexp.MustCompile(`(?m)^\s*>\s*`)
+	urlRe       = regexp.MustCompile(`(https?://|www\.)[A-Za-z0-9\-._~:/?#@!$&'*+,;=%]+`)
+	actionRes   = []*regexp.Regexp{
+		regexp.MustCompile(`（[^（）]*）`),
+		regexp.MustCompile(`\([^()]*\)`),
+		regexp.MustCompile(`【[^【】]*】`),
+		regexp.MustCompile(`\[[^\[\]]*\]`),
+		regexp.MustCompile(`\*[^*\n]+\*`),
+	}
+	// 流结束时仍未闭合的括号，从左括号开始全部丢弃
+	unclosedActionRe = regexp.MustCompile(`[（(【\[*][^）)】\]*]*$`)
+	spaceRe          = regexp.MustCompile(`\s+`)
+
+	percentRe = regexp.MustCompile(`(\d+(?:\.\d+)?)[%％]`)
+	yearRe    = regexp.MustCompile(`\d+年`)
+	phoneRe   = regexp.MustCompile(`\b(1[3-9]\d{9}|0\d{2,3}-\d{7,8}|\d{3,4}-\d{3,4}-\d{4})\b`)
+	clockRe   = regexp.MustCompile(`(\d{1,2})[:：](\d{2})`)
+	decimalRe = regexp.MustCompile(`(\d+)\.(\d+)`)
+	integerRe = regexp.MustCompile(`\d+`)
+
+	quoteReplacer = strings.NewReplacer("“", "", "”", "", "「", "", "」", "", "『", "", "』", "", "\"", "", "`", "", "——", "，", "~", "")
+)
+
+// SanitizeSpeech 位于 LLM 与 TTS 之间：把 token 流按句合并，并按角色配置清理成适合朗读的文本。
+// 括号未闭合时不会断句，保证跨 token 的动作描写能被整体去掉。
+func SanitizeSpeech(ctx context.Context, tokens <-chan string, opts domain.SpeechOptions) <-chan string {
+	out := make(chan string, 8)
+
+	go func() {
+		defer close(out)
+
+		var buf strings.Builder
+		timer := time.NewTimer(2 * time.Second)
+		defer timer.Stop()
+
+		// flush 念出 buf 中前 n 个字节，剩余部分留到下一句
+		flush := func(n int, final bool) {
+			text := buf.String()
+			rest := text[n:]
+			text = text[:n]
+			buf.Reset()
+			buf.WriteString(rest)
+			if final && !opts.KeepActions {
+				text = unclosedActionRe.ReplaceAllString(text, "")
+			}
+			if s := Sanitize(text, opts); s != "" {
+				select {
+				case out <- s:
+				case <-ctx.Done():
+				}
+			}
+		}
+		resetTimer := func() {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(2 * time.Second)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case tk, ok := <-tokens:
+				if !ok {
+					flush(buf.Len(), true)
+					return
+				}
+				buf.WriteString(tk)
+				if strings.ContainsAny(tk, sentenceEnds) {
+					if n := sentenceBoundary(buf.String()); n > 0 {
+						flush(n, false)
+					}
+				}
+				resetTimer()
+			case <-timer.C:
+				// 模型停顿过久：括号已闭合时先把已有内容念出来
+				if balanced(buf.String()) {
+					flush(buf.Len(), false)
+				}
+				timer.Reset(2 * time.Second)
+			}
+		}
+	}()
+
+	return out
+}
+
+// SplitSentences 只把 token 流按句合并、不做清理，供审核等需要原文的环节使用
+func SplitSentences(ctx context.Context, tokens <-chan string) <-chan string {
+	return SanitizeSpeech(ctx, tokens, domain.SpeechOptions{
+		KeepActions: true, KeepMarkdown: true, KeepEmoji: true, KeepUrls: true, RawNumbers: true,
+	})
+}
+
+const sentenceEnds = "。！？!?…\n"
+
+// sentenceBoundary 返回最后一个括号已闭合的句末位置（字节数），没有时返回 0
+func sentenceBoundary(s string) int {
+	for i := len(s); i > 0; {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		if strings.ContainsRune(sentenceEnds, r) && balanced(s[:i]) {
+			return i
+		}
+		i -= size
+	}
+	return 0
+}
+
+// balanced 判断括号和单个 * 是否都已闭合
+func balanced(s string) bool {
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '（', '(', '【', '[':
+			depth++
+		case '）', ')', '】', ']':
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+	return depth == 0 && strings.Count(strings.ReplaceAll(s, "**", ""), "*")%2 == 0
+}
+
+// Sanitize 清理一段完整文本
+func Sanitize(text string, opts domain.SpeechOptions) string {
+	if !opts.KeepMarkdown {
+		text = mdImageRe.ReplaceAllString(text, "")
+		text = mdLinkRe.ReplaceAllString(text, "$1")
+		text = mdBoldRe.ReplaceAllString(text, "$2")
+		text = mdHeadingRe.ReplaceAllString(text, "")
+		text = mdListRe.ReplaceAllString(text, "")
+		text = mdQuoteRe.ReplaceAllString(text, "")
+		text = quoteReplacer.Replace(text)
+	}
+	if !opts.KeepUrls {
+		text = urlRe.ReplaceAllString(text, "")
+	}
+	if !opts.KeepActions {
+		for _, re := range actionRes {
+			text = re.ReplaceAllString(text, "")
+		}
+	}
+	if !opts.KeepEmoji {
+		text = strings.Map(func(r rune) rune {
+			if isEmoji(r) {
+				return -1
+			}
+			return r
+		}, text)
+	}
+	if !opts.RawNumbers {
+		text = NormalizeNumbers(text)
+	}
+	text = strings.TrimSpace(spaceRe.ReplaceAllString(text, " "))
+	// 只剩标点时不需要朗读
+	if strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return ""
+	}
+	return text
+}
+
+func isEmoji(r rune) bool {
+	return (r >= 0x1F000 && r <= 0x1FAFF) ||
+		(r >= 0x2600 && r <= 0x27BF) ||
+		(r >= 0x2B00 && r <= 0x2BFF) ||
+		r == 0xFE0F || r == 0x200D || r == 0x20E3
+}
+
+var digitNames = []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+
+// NormalizeNumbers 把阿拉伯数字转成中文读法：四位年份和电话号码逐位读，百分数、时间、小数、整数按口语读法
+func NormalizeNumbers(text string) string {
+	text = phoneRe.ReplaceAllStringFunc(text, func(m string) string {
+		// 电话号码中的 1 读作幺
+		return strings.ReplaceAll(readDigits(strings.ReplaceAll(m, "-", "")), "一", "幺")
+	})
+	text = percentRe.ReplaceAllStringFunc(text, func(m string) string {
+		return "百分之" + readNumber(strings.TrimRight(m, "%％"))
+	})
+	text = yearRe.ReplaceAllStringFunc(text, func(m string) string {
+		// 只有四位数是年份，其余如 70年、10年 是时长
+		n := strings.TrimSuffix(m, "年")
+		if len(n) == 4 {
+			return readDigits(n) + "年"
+		}
+		return readInteger(n) + "年"
+	})
+	text = clockRe.ReplaceAllStringFunc(text, func(m string) string {
+		parts := clockRe.FindStringSubmatch(m)
+		s := readInteger(parts[1]) + "点"
+		switch {
+		case parts[2] == "00":
+		case parts[2][0] == '0':
+			s += "零" + readInteger(parts[2]) + "分"
+		default:
+			s += readInteger(parts[2]) + "分"
+		}
+		return s
+	})
+	text = decimalRe.ReplaceAllStringFunc(text, readNumber)
+	return integerRe.ReplaceAllStringFunc(text, func(m string) string {
+		// 以 0 开头或很长的数字（电话、编号）逐位读
+		if (len(m) > 1 && m[0] == '0') || len(m) > 12 {
+			return readDigits(m)
+		}
+		return readInteger(m)
+	})
+}
+
+func readNumber(s string) string {
+	intPart, frac, ok := strings.Cut(s, ".")
+	if !ok {
+		return readInteger(intPart)
+	}
+	return readInteger(intPart) + "点" + readDigits(frac)
+}
+
+func readDigits(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		sb.WriteString(digitNames[c-'0'])
+	}
+	return sb.String()
+}
+
+// readInteger 读整数，如 10 -> 十，1005 -> 一千零五，12000 -> 一万二千；超过 12 位时逐位读
+func readInteger(s string) string {
+	s = strings.TrimLeft(s, "0")
+	if s == "" {
+		return "零"
+	}
+	if len(s) > 12 {
+		return readDigits(s)
+	}
+	units := []string{"", "十", "百", "千"}
+	sections := []string{"", "万", "亿"}
+	var groups []string
+	for len(s) > 0 {
+		n := len(s) - 4
+		if n < 0 {
+			n = 0
+		}
+		groups = append([]string{s[n:]}, groups...)
+		s = s[:n]
+	}
+	var sb strings.Builder
+	needZero := false
+	for gi, g := range groups {
+		section := sections[len(groups)-1-gi]
+		if strings.Trim(g, "0") == "" {
+			needZero = sb.Len() > 0
+			continue
+		}
+		for i, c := range g {
+			d := int(c - '0')
+			unit := units[len(g)-1-i]
+			if d == 0 {
+				needZero = sb.Len() > 0
+				continue
+			}
+			if needZero {
+				sb.WriteString("零")
+				needZero = false
+			}
+			sb.WriteString(digitNames[d])
+			sb.WriteString(unit)
+		}
+		sb.WriteString(section)
+		// 下一节以 0 开头时补一个零，如 10,0050 -> 十万零五十
+		needZero = gi+1 < len(groups) && groups[gi+1][0] == '0' && strings.Trim(groups[gi+1], "0") != ""
+	}
+	res := sb.String()
+	if strings.HasPrefix(res, "一十") {
+		res = strings.TrimPrefix(res, "一")
+	}
+	return res
+}
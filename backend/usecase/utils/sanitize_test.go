@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"context"
+	"demo/domain"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"（微笑）你好呀。", "你好呀。"},
+		{"*轻轻点头* 这个问题**很好**。", "这个问题很好。"},
+		{"## 答案\n- 第一点", "答案 第一点"},
+		{"详见 https://example.com/a?b=1 这里😀。", "详见 这里。"},
+		{"[论语](https://example.com) 很有名。", "论语 很有名。"},
+		{"我生于1879年3月14日。", "我生于一八七九年三月十四日。"},
+		{"大约35%的人，3.14，10:05 见，电话 010123。", "大约百分之三十五的人，三点一四，十点零五分 见，电话 零一零一二三。"},
+		{"这座桥用了70年，再过10年就翻新。", "这座桥用了七十年，再过十年就翻新。"},
+		{"打 13812345678 或 010-12345678。", "打 幺三八幺二三四五六七八 或 零幺零幺二三四五六七八。"},
+		{"（叹气）", ""},
+	}
+	for _, c := range cases {
+		if got := Sanitize(c.in, domain.SpeechOptions{}); got != c.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+	if got := Sanitize("（微笑）今年2024年", domain.SpeechOptions{KeepActions: true, RawNumbers: true}); got != "（微笑）今年2024年" {
+		t.Errorf("options ignored: %q", got)
+	}
+}
+
+func TestReadInteger(t *testing.T) {
+	cases := map[string]string{
+		"0": "零", "10": "十", "15": "十五", "110": "一百一十", "1005": "一千零五",
+		"12000": "一万二千", "105000": "十万五千", "100050": "十万零五十", "100000001": "一亿零一",
+	}
+	for in, want := range cases {
+		if got := readInteger(in); got != want {
+			t.Errorf("readInteger(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeLongNumbers(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"共1234567890123个", "共一二三四五六七八九零一二三个"},
+		{"约1234567890123.5", "约一二三四五六七八九零一二三点五"},
+		{"增长1234567890123%", "增长百分之一二三四五六七八九零一二三"},
+		{"1234567890123年", "一二三四五六七八九零一二三年"},
+		{"3.14", "三点一四"},
+		{"50%", "百分之五十"},
+	}
+	for _, c := range cases {
+		if got := NormalizeNumbers(c.in); got != c.want {
+			t.Errorf("NormalizeNumbers(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestSanitizeSpeechAcrossTokens(t *testing.T) {
+	tokens := make(chan string)
+	go func() {
+		for _, tk := range []string{"（微", "笑。）", "你好", "！我是", "孔子。（拱手"} {
+			tokens <- tk
+		}
+		close(tokens)
+	}()
+	var got []string
+	for s := range SanitizeSpeech(context.Background(), tokens, domain.SpeechOptions{}) {
+		got = append(got, s)
+	}
+	if strings.Join(got, "|") != "你好！|我是孔子。" {
+		t.Errorf("sentences = %q", got)
+	}
+}
+
+func TestSplitSentencesKeepsDigits(t *testing.T) {
+	tokens := make(chan string)
+	go func() {
+		for _, tk := range []string{"电话是1381234", "5678。（笑）"} {
+			tokens <- tk
+		}
+		close(tokens)
+	}()
+	var got []string
+	for s := range SplitSentences(context.Background(), tokens) {
+		got = append(got, s)
+	}
+	if len(got) == 0 || got[0] != "电话是13812345678。" {
+		t.Errorf("sentences = %q", got)
+	}
+}
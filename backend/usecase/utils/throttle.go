@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle 统计窗口内的失败次数，超过上限后锁定一段时间，并发安全
+type Throttle struct {
+	mu      sync.Mutex
+	max     int
+	window  time.Duration
+	lockout time.Duration
+	entries map[string]*throttleEntry
+	now     func() time.Time
+}
+
+type throttleEntry struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+func NewThrottle(max int, window, lockout time.Duration) *Throttle {
+	return &Throttle{max: max, window: window, lockout: lockout, entries: map[string]*throttleEntry{}, now: time.Now}
+}
+
+// Locked 返回 key 是否被锁定以及剩余的锁定时间
+func (t *Throttle) Locked(key string) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.entries[key]
+	if !ok {
+		return false, 0
+	}
+	if left := e.lockedUntil.Sub(t.now()); left > 0 {
+		return true, left
+	}
+	return false, 0
+}
+
+// Fail 记录一次失败，达到上限时开始锁定
+func (t *Throttle) Fail(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	t.gc(now)
+	e, ok := t.entries[key]
+	if !ok || now.Sub(e.windowStart) > t.window {
+		e = &throttleEntry{windowStart: now}
+		t.entries[key] = e
+	}
+	e.failures++
+	if e.failures >= t.max {
+		e.lockedUntil = now.Add(t.lockout)
+		e.failures = 0
+		e.windowStart = now
+	}
+}
+
+// Reset 成功后清除计数
+func (t *Throttle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, key)
+}
+
+// gc 条目较多时清理已过期的记录
+func (t *Throttle) gc(now time.Time) {
+	if len(t.entries) < 10000 {
+		return
+	}
+	for k, e := range t.entries {
+		if now.Sub(e.windowStart) > t.window && now.After(e.lockedUntil) {
+			delete(t.entries, k)
+		}
+	}
+}
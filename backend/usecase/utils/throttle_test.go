@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	now := time.Unix(0, 0)
+	th := NewThrottle(3, time.Minute, 5*time.Minute)
+	th.now = func() time.Time { return now }
+
+	th.Fail("a")
+	th.Fail("a")
+	if locked, _ := th.Locked("a"); locked {
+		t.Fatal("locked before reaching the limit")
+	}
+	th.Fail("a")
+	if locked, left := th.Locked("a"); !locked || left != 5*time.Minute {
+		t.Fatalf("locked = %v, left = %v", locked, left)
+	}
+	if locked, _ := th.Locked("b"); locked {
+		t.Fatal("other keys should not be locked")
+	}
+
+	now = now.Add(5 * time.Minute)
+	if locked, _ := th.Locked("a"); locked {
+		t.Fatal("lock should expire")
+	}
+
+	// 窗口过期后重新计数
+	th.Fail("c")
+	th.Fail("c")
+	now = now.Add(2 * time.Minute)
+	th.Fail("c")
+	if locked, _ := th.Locked("c"); locked {
+		t.Fatal("failures outside the window should not count")
+	}
+}
@@ -11,9 +11,12 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 )
@@ -44,8 +47,36 @@ type addition struct {
 }
 
 type TtsStream struct {
-	l      *log.Logger
-	config *config.Config
+	l         *log.Logger
+	config    *config.Config
+	speed     float64
+	usage     *TtsUsage
+	sentences bool
+}
+
+// WithSpeed 设置语速，默认 1.0
+func (t *TtsStream) WithSpeed(speed float64) *TtsStream {
+	t.speed = speed
+	return t
+}
+
+// WithSentenceEnds 每句合成完后输出一个 End 为 true 的空块，并在同一连接上继续合成后续的句子，
+// 供需要按句区分音频的调用方（如按句缓存）使用
+func (t *TtsStream) WithSentenceEnds() *TtsStream {
+	t.sentences = true
+	return t
+}
+
+// TtsUsage 累计送去合成的字数和接口返回的音频时长（addition.duration），用于计量
+type TtsUsage struct {
+	Chars      atomic.Int64
+	DurationMs atomic.Int64
+}
+
+// WithUsage 之后的合成把用量累加到 u
+func (t *TtsStream) WithUsage(u *TtsUsage) *TtsStream {
+	t.usage = u
+	return t
 }
 
 func NewTtsStream(l *log.Logger, c *config.Config) *TtsStream {
@@ -59,6 +90,7 @@ func NewTtsStream(l *log.Logger, c *config.Config) *TtsStream {
 type PCMChunk struct {
 	Seq     int     // 序号（服务端的 Sequence）
 	Samples []int16 // 解码后的 PCM 采样数据
+	End     bool    // 一句结束，只在 WithSentenceEnds 时出现
 }
 
 // --- 核心：合句逻辑 ---
@@ -123,6 +155,10 @@ func (t *TtsStream) TtsStream(
 	textChunks <-chan string, // 输入句子块
 	voiceType string,
 ) (<-chan PCMChunk, <-chan error) {
+	speed := t.speed
+	if speed <= 0 {
+		speed = 1.0
+	}
 
 	out := make(chan PCMChunk, 16)
 	errCh := make(chan error, 1)
@@ -149,7 +185,7 @@ func (t *TtsStream) TtsStream(
 				Audio: audioParam{
 					VoiceType:  voiceType,
 					Encoding:   "pcm",
-					SpeedRatio: 1.0,
+					SpeedRatio: speed,
 				},
 				Request: requestParam{
 					Text: chunk,
@@ -160,6 +196,9 @@ func (t *TtsStream) TtsStream(
 				errCh <- fmt.Errorf("send text chunk fail: %w", err)
 				return
 			}
+			if t.usage != nil {
+				t.usage.Chars.Add(int64(utf8.RuneCountInString(chunk)))
+			}
 		}
 	}()
 
@@ -170,6 +209,9 @@ func (t *TtsStream) TtsStream(
 		defer c.Close()
 
 		var mu sync.Mutex
+		var decoding sync.WaitGroup
+		// 任何路径退出前都等解码协程发完，close(out) 在它之后执行
+		defer decoding.Wait()
 		seqBuf := make(map[int][]int16)
 		expectSeq := 0
 
@@ -193,7 +235,9 @@ func (t *TtsStream) TtsStream(
 				}
 
 				// 并发解码
+				decoding.Add(1)
 				go func(seq int, raw []byte) {
+					defer decoding.Done()
 					samples := make([]int16, len(raw)/2)
 					_ = binary.Read(bytes.NewReader(raw), binary.LittleEndian, &samples)
 
@@ -215,10 +259,89 @@ func (t *TtsStream) TtsStream(
 			}
 
 			if resp.Sequence < 0 {
-				return
+				if t.usage != nil && resp.Addition != nil {
+					ms, _ := strconv.ParseInt(resp.Addition.Duration, 10, 64)
+					t.usage.DurationMs.Add(ms)
+				}
+				if !t.sentences {
+					return
+				}
+				// 等这一句的音频都发出去再标记结束，下一句的序号重新开始
+				decoding.Wait()
+				out <- PCMChunk{Seq: resp.Sequence, End: true}
+				seqBuf = make(map[int][]int16)
+				expectSeq = 0
 			}
 		}
 	}()
 
 	return out, errCh
 }
+
+// TtsSampleRate 合成 PCM 的采样率，与前端播放一致
+const TtsSampleRate = 16000
+
+// ttsOnceClient 非流式合成用，超时防止服务端无响应时请求一直挂着
+var ttsOnceClient = &http.Client{Timeout: time.Minute}
+
+// Synthesize 合成一段完整文本，返回 16 位小端 PCM
+func (t *TtsStream) Synthesize(ctx context.Context, text, voiceType string, speed float64) ([]byte, error) {
+	textCh := make(chan string, 1)
+	textCh <- text
+	close(textCh)
+	pcmStream, errCh := t.WithSpeed(speed).TtsStream(ctx, textCh, voiceType)
+	var buf bytes.Buffer
+	for pcm := range pcmStream {
+		for _, s := range pcm.Samples {
+			buf.WriteByte(byte(s))
+			buf.WriteByte(byte(s >> 8))
+		}
+	}
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if buf.Len() == 0 {
+		return nil, fmt.Errorf("tts returned no audio")
+	}
+	return buf.Bytes(), nil
+}
+
+// TtsOnce 调用非流式接口合成一段文本，返回 encoding 指定格式的音频（如 mp3）
+func (t *TtsStream) TtsOnce(ctx context.Context, text, voiceType, encoding string, speed float64) ([]byte, error) {
+	if speed <= 0 {
+		speed = 1.0
+	}
+	body, _ := json.Marshal(&ttsRequest{
+		Audio:   audioParam{VoiceType: voiceType, Encoding: encoding, SpeedRatio: speed},
+		Request: requestParam{Text: text},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(t.config.Tts.BaseUrl, "/")+"/voice/tts", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.config.Tts.ApiKey)
+	resp, err := ttsOnceClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send tts request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tts api returned non-200 status: %s", resp.Status)
+	}
+	var result relayTTSResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode tts response: %w", err)
+	}
+	audio, err := base64.StdEncoding.DecodeString(result.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tts audio: %w", err)
+	}
+	if len(audio) == 0 {
+		return nil, fmt.Errorf("tts returned no audio")
+	}
+	return audio, nil
+}
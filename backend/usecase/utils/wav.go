@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// WriteWavHeader 写入单声道 PCM 的 44 字节 WAV 头
+func WriteWavHeader(w io.Writer, dataSize int64, sampleRate, bitDepth int) error {
+	var header [44]byte
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(36+dataSize))
+	copy(header[8:], "WAVE")
+	copy(header[12:], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1)
+	binary.LittleEndian.PutUint16(header[22:], 1)
+	binary.LittleEndian.PutUint32(header[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(sampleRate*bitDepth/8))
+	binary.LittleEndian.PutUint16(header[32:], uint16(bitDepth/8))
+	binary.LittleEndian.PutUint16(header[34:], uint16(bitDepth))
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], uint32(dataSize))
+	_, err := w.Write(header[:])
+	return err
+}
+
+// PCMToWav 把 16 位小端 PCM 封装成 WAV
+func PCMToWav(pcm []byte, sampleRate int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	_ = WriteWavHeader(&buf, int64(len(pcm)), sampleRate, 16)
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+// ParseWav 取出 WAV 中的 PCM 数据，只支持单声道 16 位 PCM
+func ParseWav(wav []byte) (pcm []byte, sampleRate int, err error) {
+	if len(wav) < 12 || string(wav[0:4]) != "RIFF" || string(wav[8:12]) != "WAVE" {
+		return nil, 0, errors.New("not a wav file")
+	}
+	var format bool
+	for off := 12; off+8 <= len(wav); {
+		id := string(wav[off : off+4])
+		size := int(binary.LittleEndian.Uint32(wav[off+4:]))
+		body := wav[off+8:]
+		if size > len(body) {
+			size = len(body)
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, errors.New("invalid fmt chunk")
+			}
+			if binary.LittleEndian.Uint16(body[0:]) != 1 || binary.LittleEndian.Uint16(body[2:]) != 1 || binary.LittleEndian.Uint16(body[14:]) != 16 {
+				return nil, 0, errors.New("only mono 16-bit pcm wav is supported")
+			}
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:]))
+			format = true
+		case "data":
+			if !format {
+				return nil, 0, errors.New("data chunk before fmt chunk")
+			}
+			return body[:size], sampleRate, nil
+		}
+		off += 8 + size + size%2
+	}
+	return nil, 0, errors.New("no data chunk")
+}
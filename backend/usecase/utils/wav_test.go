@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseWav(t *testing.T) {
+	pcm := []byte{1, 0, 2, 0, 3, 0}
+	got, rate, err := ParseWav(PCMToWav(pcm, 16000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != 16000 || !bytes.Equal(got, pcm) {
+		t.Errorf("got %v at %d Hz", got, rate)
+	}
+	if _, _, err := ParseWav([]byte("hello")); err == nil {
+		t.Error("expected error for non-wav input")
+	}
+}
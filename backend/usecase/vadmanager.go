@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"context"
 	"demo/config"
+	"demo/domain"
 	"demo/pkg/log"
-	"encoding/binary"
+	"demo/usecase/utils"
 	"fmt"
-	"io"
 	"sync"
 
 	"github.com/baabaaox/go-webrtcvad"
@@ -40,6 +40,10 @@ type ASRResult struct {
 	Text    string
 	SegID   int
 	FileURL string
+	// 录音在 OSS 上的 key 和时长
+	Audio domain.MessageAudio
+	// AsrMs 识别接口计费的音频时长
+	AsrMs int64
 }
 
 // StateChangeFn 当状态变化时回调（上层可把状态推给前端）
@@ -144,6 +148,11 @@ func (v *VadManager) setState(s VadState) {
 	v.logger.Info("vad state changed", log.Int("from", int(old)), log.Int("to", int(s)))
 }
 
+// OnResponseStart 上层主动说话（如开场白）时调用，播报期间丢弃音频帧
+func (v *VadManager) OnResponseStart() {
+	v.setState(StateResponding)
+}
+
 // OnResponseDone 由上层在 TTS 播报完成或中断后调用，使状态回到 Idle
 func (v *VadManager) OnResponseDone() {
 	v.setState(StateIdle)
@@ -219,7 +228,7 @@ func (v *VadManager) ProcessAudioStream(ctx context.Context, audioChunks <-chan
 func (v *VadManager) handleSegment(ctx context.Context, segID int, seg [][]byte) error {
 	var buf bytes.Buffer
 	dataSize := int64(len(seg) * BytesPerFrame)
-	if err := writeWavHeader(&buf, dataSize); err != nil {
+	if err := utils.WriteWavHeader(&buf, dataSize, SampleRate, BitDepth); err != nil {
 		v.setState(StateIdle)
 		return err
 	}
@@ -238,7 +247,7 @@ func (v *VadManager) handleSegment(ctx context.Context, segID int, seg [][]byte)
 		v.setState(StateIdle)
 		return err
 	}
-	fileUrl := fmt.Sprintf("%s/%s/%s", v.config.EndPoint, v.config.Oss.BucketName, fileKey)
+	fileUrl := v.fileUsecase.FileUrl(fileKey)
 	v.logger.Info("VAD segment uploaded", log.String("id", id), log.String("url", fileUrl))
 
 	// 调用 ASR（如果 asrUsecase.Asr 支持 ctx，传入 ctx）
@@ -254,10 +263,20 @@ func (v *VadManager) handleSegment(ctx context.Context, segID int, seg [][]byte)
 		text = result.Data.Result.Text
 	}
 
+	audio := domain.MessageAudio{
+		AudioKey:      fileKey,
+		AudioDuration: int(dataSize * 1000 / (SampleRate * BitDepth / 8)),
+	}
+	// 接口没有返回时长时按录音时长计
+	asrMs := result.DurationMs()
+	if asrMs == 0 {
+		asrMs = int64(audio.AudioDuration)
+	}
+
 	// 发回上层，不阻塞主 loop
 	if v.resultChan != nil {
 		select {
-		case v.resultChan <- ASRResult{Text: text, SegID: segID, FileURL: fileUrl}:
+		case v.resultChan <- ASRResult{Text: text, SegID: segID, FileURL: fileUrl, Audio: audio, AsrMs: asrMs}:
 		default:
 			// 如果上层接收慢，避免阻塞
 			v.logger.Warn("resultChan full, dropping asr result")
@@ -268,22 +287,3 @@ func (v *VadManager) handleSegment(ctx context.Context, segID int, seg [][]byte)
 	v.setState(StateResponding)
 	return nil
 }
-
-func writeWavHeader(w io.Writer, dataSize int64) error {
-	var header [44]byte
-	copy(header[0:], "RIFF")
-	binary.LittleEndian.PutUint32(header[4:], uint32(36+dataSize))
-	copy(header[8:], "WAVE")
-	copy(header[12:], "fmt ")
-	binary.LittleEndian.PutUint32(header[16:], 16)
-	binary.LittleEndian.PutUint16(header[20:], 1)
-	binary.LittleEndian.PutUint16(header[22:], 1)
-	binary.LittleEndian.PutUint32(header[24:], SampleRate)
-	binary.LittleEndian.PutUint32(header[28:], SampleRate*1*BitDepth/8)
-	binary.LittleEndian.PutUint16(header[32:], 1*BitDepth/8)
-	binary.LittleEndian.PutUint16(header[34:], BitDepth)
-	copy(header[36:], "data")
-	binary.LittleEndian.PutUint32(header[40:], uint32(dataSize))
-	_, err := w.Write(header[:])
-	return err
-}
@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"demo/config"
+	"demo/domain"
+	"demo/pkg/log"
+	"demo/usecase/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// voiceCacheTTL 音色列表缓存时间
+	voiceCacheTTL = time.Hour
+	// voiceFetchTimeout 拉取音色列表的超时时间
+	voiceFetchTimeout = 10 * time.Second
+	// voicePreviewTimeout 生成一段试听的超时时间
+	voicePreviewTimeout = 30 * time.Second
+)
+
+// VoiceUsecase 语音服务的音色列表（带缓存）和试听片段
+type VoiceUsecase struct {
+	l           *log.Logger
+	config      *config.Config
+	client      *http.Client
+	fileUsecase *FileUsecase
+
+	mu        sync.Mutex
+	voices    []domain.Voice
+	fetchedAt time.Time
+	group     singleflight.Group
+}
+
+func NewVoiceUsecase(l *log.Logger, c *config.Config, file *FileUsecase) *VoiceUsecase {
+	return &VoiceUsecase{
+		l:           l.WithModule("VoiceUsecase"),
+		config:      c,
+		client:      &http.Client{},
+		fileUsecase: file,
+	}
+}
+
+// ListVoices 返回过滤后的音色列表
+func (u *VoiceUsecase) ListVoices(ctx context.Context, q domain.VoiceQuery) ([]domain.Voice, error) {
+	voices, err := u.voiceList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(voices, func(v domain.Voice, _ int) bool {
+		return (q.Language == "" || v.Language == q.Language) &&
+			(q.Gender == "" || v.Gender == q.Gender) &&
+			(q.Category == "" || v.Category == q.Category)
+	}), nil
+}
+
+// GetVoice 按 voice_type 查找音色
+func (u *VoiceUsecase) GetVoice(ctx context.Context, voiceType string) (domain.Voice, error) {
+	voices, err := u.voiceList(ctx)
+	if err != nil {
+		return domain.Voice{}, err
+	}
+	for _, v := range voices {
+		if v.Type == voiceType {
+			return v, nil
+		}
+	}
+	return domain.Voice{}, fmt.Errorf("unknown voice: %s", voiceType)
+}
+
+// voiceList 缓存过期时重新拉取，拉取失败时继续使用旧的缓存
+func (u *VoiceUsecase) voiceList(ctx context.Context) ([]domain.Voice, error) {
+	u.mu.Lock()
+	voices, fresh := u.voices, time.Since(u.fetchedAt) < voiceCacheTTL
+	u.mu.Unlock()
+	if voices != nil && fresh {
+		return voices, nil
+	}
+	v, err, _ := u.group.Do("list", func() (any, error) {
+		// 结果由所有等待者共享，不能因为第一个调用方断开而失败
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), voiceFetchTimeout)
+		defer cancel()
+		return u.fetchVoices(ctx)
+	})
+	if err != nil {
+		if voices != nil {
+			u.l.Warn("refresh voice list failed, using cached list", log.Error(err))
+			return voices, nil
+		}
+		return nil, err
+	}
+	voices = v.([]domain.Voice)
+	u.mu.Lock()
+	u.voices, u.fetchedAt = voices, time.Now()
+	u.mu.Unlock()
+	return voices, nil
+}
+
+func (u *VoiceUsecase) fetchVoices(ctx context.Context) ([]domain.Voice, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(u.config.Tts.BaseUrl, "/")+"/voice/list", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+u.config.Tts.ApiKey)
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list voices: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("voice list api returned non-200 status: %s", resp.Status)
+	}
+	var voices []domain.Voice
+	if err := json.NewDecoder(resp.Body).Decode(&voices); err != nil {
+		return nil, fmt.Errorf("failed to decode voice list: %w", err)
+	}
+	for i := range voices {
+		voices[i].Language, voices[i].Gender = parseVoiceType(voices[i].Type)
+	}
+	return voices, nil
+}
+
+var voiceLanguages = []string{"zh", "en", "ja", "ko", "yue", "es", "fr", "de", "ru"}
+
+// parseVoiceType 从 voice_type 推断语言和性别，eg：qiniu_zh_female_tmjxxy -> zh, female
+func parseVoiceType(voiceType string) (language, gender string) {
+	for _, part := range strings.Split(strings.ToLower(voiceType), "_") {
+		switch {
+		case part == "female" || part == "male":
+			if gender == "" {
+				gender = part
+			}
+		case language == "" && lo.Contains(voiceLanguages, part):
+			language = part
+		}
+	}
+	return language, gender
+}
+
+// Preview 返回音色的试听片段，第一次请求时合成并保存到 OSS
+func (u *VoiceUsecase) Preview(ctx context.Context, voiceType string) (domain.VoicePreview, error) {
+	voice, err := u.GetVoice(ctx, voiceType)
+	if err != nil {
+		return domain.VoicePreview{}, err
+	}
+	key := fmt.Sprintf("voices/preview/%s.wav", voice.Type)
+	_, err, _ = u.group.Do("preview:"+voice.Type, func() (any, error) {
+		exists, err := u.fileUsecase.FileExists(ctx, key)
+		if err != nil || exists {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), voicePreviewTimeout)
+		defer cancel()
+		pcm, err := utils.NewTtsStream(u.l, u.config).Synthesize(ctx, previewText(voice), voice.Type, 1.0)
+		if err != nil {
+			return nil, err
+		}
+		wav := utils.PCMToWav(pcm, utils.TtsSampleRate)
+		_, err = u.fileUsecase.UploadFileWithWriter(ctx, key, bytes.NewReader(wav), int64(len(wav)))
+		return nil, err
+	})
+	if err != nil {
+		return domain.VoicePreview{}, fmt.Errorf("failed to generate voice preview: %w", err)
+	}
+	return domain.VoicePreview{VoiceType: voice.Type, Url: u.fileUsecase.FileUrl(key)}, nil
+}
+
+func previewText(v domain.Voice) string {
+	if v.Language == "en" {
+		return "Hello, nice to meet you. This is how I sound."
+	}
+	return "你好，很高兴认识你，这是我的声音。"
+}
@@ -0,0 +1,20 @@
+package usecase
+
+import "testing"
+
+func TestParseVoiceType(t *testing.T) {
+	cases := []struct {
+		voiceType, language, gender string
+	}{
+		{"qiniu_zh_female_tmjxxy", "zh", "female"},
+		{"zh_male_laobai", "zh", "male"},
+		{"qiniu_en_male_ysyyn", "en", "male"},
+		{"custom_voice", "", ""},
+	}
+	for _, c := range cases {
+		language, gender := parseVoiceType(c.voiceType)
+		if language != c.language || gender != c.gender {
+			t.Errorf("parseVoiceType(%q) = %q, %q", c.voiceType, language, gender)
+		}
+	}
+}
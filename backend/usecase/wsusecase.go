@@ -1,34 +1,57 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
 	"demo/config"
 	"demo/domain"
 	"demo/pkg/log"
+	"demo/repo"
 	"demo/usecase/utils"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+// ErrGuestQuota 游客的对话次数已用完
+var ErrGuestQuota = errors.New("guest quota exceeded")
+
 type WsUseCase struct {
-	logger      *log.Logger
-	config      *config.Config
-	asrusecase  *AsrUsecase
-	llmusecase  *LlmUsecase
-	fileusecase *FileUsecase
+	logger       *log.Logger
+	config       *config.Config
+	asrusecase   *AsrUsecase
+	llmusecase   *LlmUsecase
+	fileusecase  *FileUsecase
+	roleusecase  RoleUsecase
+	moderation   *ModerationUsecase
+	ttsCache     *TtsCache
+	conversation *ConversationUsecase
+	userRepo     *repo.UserRepo
+	usage        *UsageUsecase
 }
 
-func NewWsUsecase(l *log.Logger, c *config.Config, asr *AsrUsecase, llm *LlmUsecase, file *FileUsecase) *WsUseCase {
+func NewWsUsecase(l *log.Logger, c *config.Config, asr *AsrUsecase, llm *LlmUsecase, file *FileUsecase, role RoleUsecase, moderation *ModerationUsecase, ttsCache *TtsCache, conversation *ConversationUsecase, userRepo *repo.UserRepo, usage *UsageUsecase) *WsUseCase {
 	return &WsUseCase{
-		logger:      l,
-		config:      c,
-		asrusecase:  asr,
-		llmusecase:  llm,
-		fileusecase: file,
+		logger:       l,
+		config:       c,
+		asrusecase:   asr,
+		llmusecase:   llm,
+		fileusecase:  file,
+		roleusecase:  role,
+		moderation:   moderation,
+		ttsCache:     ttsCache,
+		conversation: conversation,
+		userRepo:     userRepo,
+		usage:        usage,
 	}
 
 }
@@ -63,13 +86,78 @@ func vadStateToString(s VadState) string {
 	}
 }
 
-// HanderWs2 使用 VadManager 与 domain.Msg 完成全流程
-func (w *WsUseCase) HanderWs2(ws *websocket.Conn, userid string, roleid int) error {
-	w.logger.Info("new ws connection (HanderWs2)", log.String("userid", userid))
+// HanderWs2 使用 VadManager 与 domain.Msg 完成全流程；conversationID 为 0 时继续与角色最近的对话，否则恢复该对话
+func (w *WsUseCase) HanderWs2(ws *websocket.Conn, userid string, roleid, conversationID int) error {
+	w.logger.Info("new ws connection (HanderWs2)", log.String("userid", userid), log.Int("conversation_id", conversationID))
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	user, err := w.userRepo.GetUserByID(ctx, userid)
+	if err != nil {
+		w.logger.Error("get user failed", log.String("userid", userid), log.Error(err))
+		errMsg := &domain.Msg{Type: domain.MsgTypeError, Data: []byte(`{"error":"user not found"}`)}
+		if data, e := errMsg.Encode(); e == nil {
+			_ = ws.WriteMessage(websocket.TextMessage, data)
+		}
+		return err
+	}
+	// 游客只能对话有限的轮数
+	if left, err := w.guestTurnsLeft(ctx, user); err != nil {
+		return err
+	} else if left == 0 {
+		errMsg := &domain.Msg{Type: domain.MsgTypeError, Data: []byte(`{"error":"guest quota exceeded, register to continue"}`)}
+		if data, e := errMsg.Encode(); e == nil {
+			_ = ws.WriteMessage(websocket.TextMessage, data)
+		}
+		return ErrGuestQuota
+	}
+
+	// 日或月额度用完时不开始对话
+	if err := w.usage.Check(ctx, userid); err != nil {
+		w.sendQuotaExceeded(ws, err)
+		return err
+	}
+
+	conv, err := w.conversation.Open(ctx, userid, roleid, conversationID)
+	if err != nil {
+		w.logger.Error("open conversation failed", log.Int("conversation_id", conversationID), log.Error(err))
+		errMsg := &domain.Msg{Type: domain.MsgTypeError, Data: []byte(`{"error":"conversation not found"}`)}
+		if data, e := errMsg.Encode(); e == nil {
+			_ = ws.WriteMessage(websocket.TextMessage, data)
+		}
+		return err
+	}
+	// 恢复对话时以对话所属的角色为准
+	roleid = conv.RoleID
+
+	role, err := w.roleusecase.GetRole(ctx, roleid)
+	if err == nil && !role.VisibleTo(userid) {
+		err = fmt.Errorf("role %d is not visible to user %s", roleid, userid)
+	}
+	if err != nil {
+		w.logger.Error("get role failed", log.Int("roleid", roleid), log.Error(err))
+		errMsg := &domain.Msg{Type: domain.MsgTypeError, Data: []byte(`{"error":"role not found"}`)}
+		if data, e := errMsg.Encode(); e == nil {
+			_ = ws.WriteMessage(websocket.TextMessage, data)
+		}
+		return err
+	}
+
+	// 朗读使用角色的音色和用户设置的语速
+	voice := ttsVoice{Type: roleVoice(role), Speed: 1.0}
+	if user.VoiceSpeed > 0 {
+		voice.Speed = user.VoiceSpeed
+	}
+
+	// 告诉前端本次使用的对话线程，之后可以用它恢复对话
+	if b, err := json.Marshal(conv); err == nil {
+		convMsg := &domain.Msg{Type: domain.MsgTypeConversation, Data: b}
+		if data, err := convMsg.Encode(); err == nil {
+			_ = ws.WriteMessage(websocket.TextMessage, data)
+		}
+	}
+
 	// channel: 音频数据推给 VAD
 	audioChan := make(chan []byte, 200)
 	defer close(audioChan)
@@ -112,6 +200,38 @@ func (w *WsUseCase) HanderWs2(ws *websocket.Conn, userid string, roleid int) err
 
 	// helper：开始处理一个 ASR 结果（串行处理 resultChan 的每个消息）
 	go func() {
+		// 0) 开场白：按角色配置先主动说一句，期间不收音，可被 interrupt 打断
+		vadMgr.OnResponseStart()
+		greetCtx, greetCancel := context.WithCancel(ctx)
+		responseCancelMu.Lock()
+		responseCancel = greetCancel
+		responseCancelMu.Unlock()
+		greeting, err := w.llmusecase.Greeting(greetCtx, conv, role)
+		if err != nil {
+			w.logger.Error("generate greeting failed", log.Error(err))
+		}
+		if greeting != nil {
+			// 先按原文审核（电话、证件号等规则需要看到阿拉伯数字），再清理成朗读文本
+			speech := w.moderation.ModerateStream(greetCtx, userid, roleid, utils.SplitSentences(greetCtx, greeting.Tokens), RefusalFor(role))
+			speech = utils.SanitizeSpeech(greetCtx, speech, role.Speech)
+			anCh, textCh := collectTokens(greetCtx, speech)
+			var greetAudio bytes.Buffer
+			var ttsUsage utils.TtsUsage
+			w.speak(greetCtx, ws, anCh, voice, &greetAudio, &ttsUsage)
+			if text := <-textCh; text != "" {
+				audio := w.saveSpeech(greetAudio.Bytes())
+				if err := w.llmusecase.SaveGreeting(context.Background(), conv, text, greeting.Model, audio); err != nil {
+					w.logger.Error("save greeting failed", log.Error(err))
+				}
+			}
+			w.recordUsage(conv, 0, greeting, &ttsUsage)
+		}
+		responseCancelMu.Lock()
+		responseCancel = nil
+		responseCancelMu.Unlock()
+		greetCancel()
+		vadMgr.OnResponseDone()
+
 		for asr := range resultChan {
 			// 每次开始处理新的 ASR 时，确保没有旧的 responseCancel 未清理
 			responseCancelMu.Lock()
@@ -126,6 +246,27 @@ func (w *WsUseCase) HanderWs2(ws *websocket.Conn, userid string, roleid int) err
 			responseCancel = cancelFn
 			responseCancelMu.Unlock()
 
+			// 本轮合成的用量，每个分支结束时和识别、模型用量一起记录
+			var ttsUsage utils.TtsUsage
+
+			// 额度在对话中途用完：记录识别用量后通知前端并关闭连接
+			if err := w.usage.Check(respCtx, userid); err != nil {
+				w.recordUsage(conv, asr.AsrMs, nil, &ttsUsage)
+				w.sendQuotaExceeded(ws, err)
+				cancelFn()
+				_ = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "quota exceeded"))
+				_ = ws.Close()
+				return
+			}
+
+			// 游客的次数在其他连接上用完：通知前端并关闭连接
+			if left, err := w.guestTurnsLeft(respCtx, user); err == nil && left == 0 {
+				w.recordUsage(conv, asr.AsrMs, nil, &ttsUsage)
+				cancelFn()
+				w.endSession(ws, []byte(`{"reason":"guest_quota"}`))
+				return
+			}
+
 			// 1) 向前端发送 ASR 结果（文本展示）
 			asrPayload := AsrResultPayload{
 				Text:    asr.Text,
@@ -138,10 +279,31 @@ func (w *WsUseCase) HanderWs2(ws *websocket.Conn, userid string, roleid int) err
 				_ = ws.WriteMessage(websocket.TextMessage, data)
 			}
 
-			// 2) LLM 生成回复（参考你原 HanderWs）
-			ms, err := w.llmusecase.FormatMessage(respCtx, userid, roleid, asr.Text)
+			// 2) 审核用户输入：block 时直接说拒绝语，不经过 LLM；rewrite 时用替换后的文本提问
+			question := asr.Text
+			inRes := w.moderation.Check(respCtx, userid, roleid, domain.ModerationInput, question)
+			if inRes.Action == domain.ModerationBlock {
+				refusal := RefusalFor(role)
+				refusalCh := make(chan string, 1)
+				refusalCh <- refusal
+				close(refusalCh)
+				w.speak(respCtx, ws, refusalCh, voice, nil, &ttsUsage)
+				w.recordUsage(conv, asr.AsrMs, nil, &ttsUsage)
+
+				responseCancelMu.Lock()
+				responseCancel = nil
+				responseCancelMu.Unlock()
+				cancelFn()
+				vadMgr.OnResponseDone()
+				continue
+			}
+			question = inRes.Text
+
+			// 3) LLM 生成回复（参考你原 HanderWs）
+			ms, err := w.llmusecase.FormatMessage(respCtx, conv, question)
 			if err != nil {
 				w.logger.Error("format message failed", log.Error(err))
+				w.recordUsage(conv, asr.AsrMs, nil, &ttsUsage)
 				// 恢复 VAD 并清理 responseCancel
 				vadMgr.OnResponseDone()
 				responseCancelMu.Lock()
@@ -149,75 +311,29 @@ func (w *WsUseCase) HanderWs2(ws *websocket.Conn, userid string, roleid int) err
 				responseCancelMu.Unlock()
 				continue
 			}
-			anCh, err := w.llmusecase.Chat(respCtx, ms)
+			tools, err := w.llmusecase.RoleTools(role)
+			if err != nil {
+				w.logger.Error("create role tools failed", log.Error(err))
+				tools = &TurnTools{}
+			}
+			reply, err := w.llmusecase.Reply(respCtx, ms, tools.Tools...)
 			if err != nil {
 				w.logger.Error("llm chat failed", log.Error(err))
+				w.recordUsage(conv, asr.AsrMs, nil, &ttsUsage)
 				vadMgr.OnResponseDone()
 				responseCancelMu.Lock()
 				responseCancel = nil
 				responseCancelMu.Unlock()
 				continue
 			}
+			// 按句审核原文，再去掉动作描写、markdown 等不适合朗读的内容后送去 TTS
+			speech := w.moderation.ModerateStream(respCtx, userid, roleid, utils.SplitSentences(respCtx, reply.Tokens), RefusalFor(role))
+			speech = utils.SanitizeSpeech(respCtx, speech, role.Speech)
+			anCh, answerCh := collectTokens(respCtx, speech)
 
-			// 3) TTS 流式合成并推给前端
-			tts := utils.NewTtsStream(w.logger, w.config)
-			// 传入 respCtx，方便外部 cancel
-			pcmStream, errCh := tts.TtsStream(respCtx, anCh, "qiniu_zh_female_tmjxxy")
-
-			// 发送 tts_start 事件
-			startMsg := &domain.Msg{Type: domain.MsgTypeTtsStart, Data: []byte(`{}`)}
-			if data, err := startMsg.Encode(); err == nil {
-				_ = ws.WriteMessage(websocket.TextMessage, data)
-			}
-
-			// 读流并发送 PCM（二进制）; 任何错误或 ctx cancel 都会中断
-			sendErr := false
-		LOOP:
-			for {
-				select {
-				case <-respCtx.Done():
-					// 被上层打断
-					w.logger.Info("response ctx canceled (interrupt)")
-					sendErr = true
-					break LOOP
-				case err := <-errCh:
-					if err != nil {
-						w.logger.Error("tts stream error", log.Error(err))
-					}
-					// tts 测试流结束或发生错误
-					break LOOP
-				case pcm, ok := <-pcmStream:
-					if !ok {
-						// 正常结束
-						break LOOP
-					}
-					// 将 int16 samples 转为 bytes（与你现有逻辑一致）
-					results := make([]byte, len(pcm.Samples)*2) // int16 -> 2 bytes each (注意：之前示例直接 byte(s) 可能丢精度)
-					// 我这里采用小端编码，将 int16 写成两字节
-					for i, s := range pcm.Samples {
-						// little endian
-						results[2*i] = byte(s)
-						results[2*i+1] = byte(s >> 8)
-					}
-					// 发送二进制 PCM
-					if err := ws.WriteMessage(websocket.BinaryMessage, results); err != nil {
-						w.logger.Error("write pcm to ws failed", log.Error(err))
-						sendErr = true
-						break LOOP
-					}
-					// 可选：也发送一个 TtsChunk 事件（meta）
-					meta := &domain.Msg{Type: domain.MsgTypeTtsChunk, Data: []byte(`{}`)}
-					if md, err := meta.Encode(); err == nil {
-						_ = ws.WriteMessage(websocket.TextMessage, md)
-					}
-				}
-			}
-
-			// 发送 tts_end（无论是正常结束还是中断）
-			endMsg := &domain.Msg{Type: domain.MsgTypeTtsEnd, Data: []byte(`{}`)}
-			if data, err := endMsg.Encode(); err == nil {
-				_ = ws.WriteMessage(websocket.TextMessage, data)
-			}
+			// 4) TTS 流式合成并推给前端，同时录下推送的音频
+			var answerAudio bytes.Buffer
+			sendErr := w.speak(respCtx, ws, anCh, voice, &answerAudio, &ttsUsage)
 
 			// 清理 responseCancel 并让 VAD 恢复 Idle（即允许新一轮语音）
 			responseCancelMu.Lock()
@@ -227,6 +343,30 @@ func (w *WsUseCase) HanderWs2(ws *websocket.Conn, userid string, roleid int) err
 				responseCancel = nil
 			}
 			responseCancelMu.Unlock()
+			cancelFn()
+
+			// 保存本轮对话（被打断时保存已生成的部分）
+			guestDone := false
+			if answer := <-answerCh; answer != "" {
+				audio := TurnAudio{Question: asr.Audio, Answer: w.saveSpeech(answerAudio.Bytes())}
+				if err := w.llmusecase.SaveTurn(context.Background(), conv, question, answer, reply, audio); err != nil {
+					w.logger.Error("save conversation failed", log.Error(err))
+				}
+				left, err := w.guestTurnsLeft(context.Background(), user)
+				guestDone = err == nil && left == 0
+			}
+			w.recordUsage(conv, asr.AsrMs, reply, &ttsUsage)
+
+			// 游客用完了次数：无论本轮是否发送成功都结束会话，主读循环随之退出
+			if guestDone {
+				w.endSession(ws, []byte(`{"reason":"guest_quota"}`))
+				return
+			}
+			// 角色调用了 end_conversation：通知前端并关闭连接
+			if tools.Ended() && !sendErr {
+				w.endSession(ws, []byte(`{}`))
+				return
+			}
 
 			// 让 VadManager 进入 Idle（等待新段）
 			vadMgr.OnResponseDone()
@@ -316,6 +456,170 @@ func (w *WsUseCase) HanderWs2(ws *websocket.Conn, userid string, roleid int) err
 	}
 }
 
+// ttsVoice 朗读使用的音色和语速
+type ttsVoice struct {
+	Type  string
+	Speed float64
+}
+
+// roleVoice 角色未配置音色时使用默认音色
+func roleVoice(role domain.Role) string {
+	if role.Voice != "" {
+		return role.Voice
+	}
+	return ttsDefaultVoice
+}
+
+// speak 把文本流合成语音推给前端（tts_start、PCM 二进制帧、tts_end），被打断或写失败时返回 true。
+// rec 不为 nil 时写入实际推送给前端的 PCM
+func (w *WsUseCase) speak(ctx context.Context, ws *websocket.Conn, textCh <-chan string, voice ttsVoice, rec io.Writer, usage *utils.TtsUsage) bool {
+	// 经过句子缓存：开场白、拒绝语等重复的短句直接推送缓存的音频
+	pcmStream, errCh := w.ttsCache.Stream(ctx, textCh, voice.Type, voice.Speed, usage)
+
+	// 发送 tts_start 事件
+	startMsg := &domain.Msg{Type: domain.MsgTypeTtsStart, Data: []byte(`{}`)}
+	if data, err := startMsg.Encode(); err == nil {
+		_ = ws.WriteMessage(websocket.TextMessage, data)
+	}
+
+	// 读流并发送 PCM（二进制）; 任何错误或 ctx cancel 都会中断
+	sendErr := false
+LOOP:
+	for {
+		select {
+		case <-ctx.Done():
+			// 被上层打断
+			w.logger.Info("response ctx canceled (interrupt)")
+			sendErr = true
+			break LOOP
+		case err := <-errCh:
+			if err != nil {
+				w.logger.Error("tts stream error", log.Error(err))
+			}
+			// tts 测试流结束或发生错误
+			break LOOP
+		case pcm, ok := <-pcmStream:
+			if !ok {
+				// 正常结束
+				break LOOP
+			}
+			// 将 int16 samples 按小端写成两字节
+			results := make([]byte, len(pcm.Samples)*2)
+			for i, s := range pcm.Samples {
+				results[2*i] = byte(s)
+				results[2*i+1] = byte(s >> 8)
+			}
+			// 发送二进制 PCM
+			if err := ws.WriteMessage(websocket.BinaryMessage, results); err != nil {
+				w.logger.Error("write pcm to ws failed", log.Error(err))
+				sendErr = true
+				break LOOP
+			}
+			if rec != nil {
+				_, _ = rec.Write(results)
+			}
+			// 可选：也发送一个 TtsChunk 事件（meta）
+			meta := &domain.Msg{Type: domain.MsgTypeTtsChunk, Data: []byte(`{}`)}
+			if md, err := meta.Encode(); err == nil {
+				_ = ws.WriteMessage(websocket.TextMessage, md)
+			}
+		}
+	}
+
+	// 发送 tts_end（无论是正常结束还是中断）
+	endMsg := &domain.Msg{Type: domain.MsgTypeTtsEnd, Data: []byte(`{}`)}
+	if data, err := endMsg.Encode(); err == nil {
+		_ = ws.WriteMessage(websocket.TextMessage, data)
+	}
+	return sendErr
+}
+
+// recordUsage 记录一轮对话的用量，reply 为空表示本轮没有调用模型
+func (w *WsUseCase) recordUsage(conv domain.Conversation, asrMs int64, reply *ChatReply, tts *utils.TtsUsage) {
+	rec := domain.UsageRecord{
+		UserID:         conv.UserID,
+		ConversationID: conv.ID,
+		AsrMs:          asrMs,
+		TtsChars:       tts.Chars.Load(),
+		TtsMs:          tts.DurationMs.Load(),
+	}
+	if reply != nil {
+		// 被打断时模型的输出协程可能还没退出，等它补上估算的用量
+		reply.Usage.Wait(time.Second)
+		rec.PromptTokens = reply.Usage.Prompt.Load()
+		rec.CompletionTokens = reply.Usage.Completion.Load()
+	}
+	w.usage.Record(context.Background(), rec)
+}
+
+// guestTurnsLeft 游客剩余的对话轮数，按已保存的提问数计算，同一游客的所有连接共用；不是游客时返回 -1
+func (w *WsUseCase) guestTurnsLeft(ctx context.Context, user domain.User) (int, error) {
+	if user.Role != domain.AccountGuest {
+		return -1, nil
+	}
+	used, err := w.conversation.CountTurns(ctx, user.ID)
+	if err != nil {
+		return 0, err
+	}
+	return max(w.config.Guest.TurnLimit-int(used), 0), nil
+}
+
+// endSession 发送 end 消息并关闭连接
+func (w *WsUseCase) endSession(ws *websocket.Conn, data []byte) {
+	endMsg := &domain.Msg{Type: domain.MsgTypeEnd, Data: data}
+	if b, err := endMsg.Encode(); err == nil {
+		_ = ws.WriteMessage(websocket.TextMessage, b)
+	}
+	_ = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "conversation ended"))
+	_ = ws.Close()
+}
+
+// sendQuotaExceeded 告诉前端哪项额度用完了
+func (w *WsUseCase) sendQuotaExceeded(ws *websocket.Conn, err error) {
+	w.logger.Info("quota exceeded", log.Error(err))
+	b, _ := json.Marshal(map[string]string{"error": err.Error()})
+	errMsg := &domain.Msg{Type: domain.MsgTypeError, Data: b}
+	if data, e := errMsg.Encode(); e == nil {
+		_ = ws.WriteMessage(websocket.TextMessage, data)
+	}
+}
+
+// saveSpeech 把角色说出的 PCM 存成 WAV，失败或没有音频时返回空
+func (w *WsUseCase) saveSpeech(pcm []byte) domain.MessageAudio {
+	if len(pcm) == 0 {
+		return domain.MessageAudio{}
+	}
+	wav := utils.PCMToWav(pcm, utils.TtsSampleRate)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	key, err := w.fileusecase.UploadFileWithWriter(ctx, fmt.Sprintf("tts_%s.wav", uuid.New().String()), bytes.NewReader(wav), int64(len(wav)))
+	if err != nil {
+		w.logger.Error("upload speech failed", log.Error(err))
+		return domain.MessageAudio{}
+	}
+	return domain.MessageAudio{AudioKey: key, AudioDuration: len(pcm) * 1000 / (utils.TtsSampleRate * 2)}
+}
+
+// collectTokens 转发 token 流，结束（或 ctx 取消）后通过第二个 channel 给出已转发的完整文本
+func collectTokens(ctx context.Context, in <-chan string) (<-chan string, <-chan string) {
+	out := make(chan string)
+	full := make(chan string, 1)
+	go func() {
+		defer close(out)
+		var sb strings.Builder
+		defer func() { full <- sb.String() }()
+		for tk := range in {
+			select {
+			case out <- tk:
+				sb.WriteString(tk)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, full
+}
+
 // 辅助函数：安全读取 vadMgr.IsVad（可能 nil）
 func vadMgrIsVadSafe(v *VadManager) bool {
 	if v == nil {
@@ -330,6 +634,11 @@ func (w *WsUseCase) HanderWs(ws *websocket.Conn, userid string, roleid int) erro
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	conv, err := w.conversation.Latest(ctx, userid, roleid)
+	if err != nil {
+		return err
+	}
+
 	// 音频帧输入给 ASR
 	pcmChan := make(chan []byte, 200)
 	defer close(pcmChan)
@@ -378,7 +687,7 @@ func (w *WsUseCase) HanderWs(ws *websocket.Conn, userid string, roleid int) erro
 			responseCancelMu.Unlock()
 
 			// 格式化并调用 LLM（返回 token 流 channel <-chan string）
-			ms, err := w.llmusecase.FormatMessage(respCtx, userid, roleid, text)
+			ms, err := w.llmusecase.FormatMessage(respCtx, conv, text)
 			if err != nil {
 				w.logger.Error("format message failed", log.Error(err))
 				// 清理